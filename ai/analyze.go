@@ -0,0 +1,263 @@
+package ai
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+// analysisSize 是分析前把图片缩小到的最长边，足够反映整体特征
+const analysisSize = 160
+
+// PaletteColor 是画面中的一种主要颜色
+type PaletteColor struct {
+	Hex   string  `json:"hex"`
+	Name  string  `json:"name"`
+	Share float64 `json:"share"`
+}
+
+// ImageMetrics 是不依赖模型、可以直接从像素计算的画面指标，比例类指标都在 0-1 之间
+type ImageMetrics struct {
+	Width          int            `json:"width"`
+	Height         int            `json:"height"`
+	Palette        []PaletteColor `json:"palette"`
+	DistinctColors int            `json:"distinct_colors"`
+	ColorDiversity float64        `json:"color_diversity"`
+	Brightness     float64        `json:"brightness"`
+	Contrast       float64        `json:"contrast"`
+	Coverage       float64        `json:"coverage"`
+	Symmetry       float64        `json:"symmetry"`
+	EdgeDensity    float64        `json:"edge_density"`
+}
+
+type rgb struct{ r, g, b float64 }
+
+func (c rgb) luma() float64 {
+	return 0.299*c.r + 0.587*c.g + 0.114*c.b
+}
+
+func (c rgb) distance(o rgb) float64 {
+	dr, dg, db := c.r-o.r, c.g-o.g, c.b-o.b
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+// bucket 把颜色量化为 8x8x8 个区间之一
+func (c rgb) bucket() int {
+	return int(c.r)>>5<<6 | int(c.g)>>5<<3 | int(c.b)>>5
+}
+
+// sample 按固定步长取样，返回缩小后的像素
+func sample(img image.Image) ([]rgb, int, int) {
+	bounds := img.Bounds()
+	step := 1
+	if longest := max(bounds.Dx(), bounds.Dy()); longest > analysisSize {
+		step = (longest + analysisSize - 1) / analysisSize
+	}
+	w, h := (bounds.Dx()+step-1)/step, (bounds.Dy()+step-1)/step
+	pixels := make([]rgb, 0, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x*step, bounds.Min.Y+y*step).RGBA()
+			// 透明像素按白色画纸处理
+			alpha := float64(a) / 0xffff
+			pixels = append(pixels, rgb{
+				r: float64(r>>8) + (1-alpha)*255,
+				g: float64(g>>8) + (1-alpha)*255,
+				b: float64(b>>8) + (1-alpha)*255,
+			})
+		}
+	}
+	return pixels, w, h
+}
+
+// Analyze 计算图片的调色板、亮度、对比度、画面覆盖率、对称性和边缘密度
+func Analyze(img image.Image) ImageMetrics {
+	pixels, w, h := sample(img)
+	metrics := ImageMetrics{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if len(pixels) == 0 {
+		return metrics
+	}
+	total := float64(len(pixels))
+
+	// 亮度和对比度
+	luma := make([]float64, len(pixels))
+	var sum, sumSq float64
+	for i, p := range pixels {
+		luma[i] = p.luma()
+		sum += luma[i]
+		sumSq += luma[i] * luma[i]
+	}
+	mean := sum / total
+	metrics.Brightness = mean / 255
+	metrics.Contrast = math.Min(1, math.Sqrt(math.Max(0, sumSq/total-mean*mean))/128)
+
+	// 调色板
+	type bucketStat struct {
+		count   int
+		r, g, b float64
+	}
+	buckets := map[int]*bucketStat{}
+	for _, p := range pixels {
+		stat := buckets[p.bucket()]
+		if stat == nil {
+			stat = &bucketStat{}
+			buckets[p.bucket()] = stat
+		}
+		stat.count++
+		stat.r += p.r
+		stat.g += p.g
+		stat.b += p.b
+	}
+	stats := make([]*bucketStat, 0, len(buckets))
+	for _, stat := range buckets {
+		stats = append(stats, stat)
+		if float64(stat.count)/total >= 0.01 {
+			metrics.DistinctColors++
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].count > stats[j].count })
+	for _, stat := range stats {
+		share := float64(stat.count) / total
+		if len(metrics.Palette) == 5 || share < 0.02 {
+			break
+		}
+		n := float64(stat.count)
+		c := rgb{stat.r / n, stat.g / n, stat.b / n}
+		metrics.Palette = append(metrics.Palette, PaletteColor{
+			Hex:   fmt.Sprintf("#%02x%02x%02x", int(c.r), int(c.g), int(c.b)),
+			Name:  colorName(c),
+			Share: math.Round(share*1000) / 1000,
+		})
+	}
+	metrics.ColorDiversity = math.Min(1, float64(metrics.DistinctColors)/16)
+
+	// 画面覆盖率：与边缘最常见的背景色差别明显的像素比例
+	background := borderColor(pixels, w, h)
+	covered := 0
+	for _, p := range pixels {
+		if p.distance(background) > 60 {
+			covered++
+		}
+	}
+	metrics.Coverage = float64(covered) / total
+
+	// 左右对称性
+	var diff float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w/2; x++ {
+			diff += math.Abs(luma[y*w+x] - luma[y*w+w-1-x])
+		}
+	}
+	if half := h * (w / 2); half > 0 {
+		metrics.Symmetry = math.Max(0, 1-diff/float64(half)/128)
+	}
+
+	// 边缘密度：Sobel 梯度较大的像素比例
+	edges, interior := 0, 0
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			at := func(dx, dy int) float64 { return luma[(y+dy)*w+x+dx] }
+			gx := at(1, -1) + 2*at(1, 0) + at(1, 1) - at(-1, -1) - 2*at(-1, 0) - at(-1, 1)
+			gy := at(-1, 1) + 2*at(0, 1) + at(1, 1) - at(-1, -1) - 2*at(0, -1) - at(1, -1)
+			if math.Hypot(gx, gy) > 128 {
+				edges++
+			}
+			interior++
+		}
+	}
+	if interior > 0 {
+		metrics.EdgeDensity = float64(edges) / float64(interior)
+	}
+
+	for _, v := range []*float64{&metrics.Brightness, &metrics.Contrast, &metrics.ColorDiversity,
+		&metrics.Coverage, &metrics.Symmetry, &metrics.EdgeDensity} {
+		*v = math.Round(*v*1000) / 1000
+	}
+	return metrics
+}
+
+// borderColor 返回图片四周最常见的颜色，通常就是画纸的颜色
+func borderColor(pixels []rgb, w, h int) rgb {
+	counts := map[int]int{}
+	sums := map[int]rgb{}
+	add := func(p rgb) {
+		key := p.bucket()
+		counts[key]++
+		s := sums[key]
+		sums[key] = rgb{s.r + p.r, s.g + p.g, s.b + p.b}
+	}
+	for x := 0; x < w; x++ {
+		add(pixels[x])
+		add(pixels[(h-1)*w+x])
+	}
+	for y := 1; y < h-1; y++ {
+		add(pixels[y*w])
+		add(pixels[y*w+w-1])
+	}
+
+	best, bestCount := 0, -1
+	for key, count := range counts {
+		if count > bestCount || (count == bestCount && key < best) {
+			best, bestCount = key, count
+		}
+	}
+	s, n := sums[best], float64(bestCount)
+	return rgb{s.r / n, s.g / n, s.b / n}
+}
+
+// colorName 返回颜色的中文名称
+func colorName(c rgb) string {
+	r, g, b := c.r/255, c.g/255, c.b/255
+	maxC, minC := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	v := maxC
+	s := 0.0
+	if maxC > 0 {
+		s = (maxC - minC) / maxC
+	}
+	switch {
+	case s < 0.15 && v > 0.85:
+		return "白色"
+	case v < 0.2:
+		return "黑色"
+	case s < 0.15:
+		return "灰色"
+	}
+
+	var hue float64
+	d := maxC - minC
+	switch maxC {
+	case r:
+		hue = math.Mod((g-b)/d, 6)
+	case g:
+		hue = (b-r)/d + 2
+	default:
+		hue = (r-g)/d + 4
+	}
+	hue *= 60
+	if hue < 0 {
+		hue += 360
+	}
+
+	switch {
+	case hue < 15 || hue >= 345:
+		return "红色"
+	case hue < 45:
+		if v < 0.6 {
+			return "棕色"
+		}
+		return "橙色"
+	case hue < 70:
+		return "黄色"
+	case hue < 160:
+		return "绿色"
+	case hue < 200:
+		return "青色"
+	case hue < 260:
+		return "蓝色"
+	case hue < 300:
+		return "紫色"
+	default:
+		return "粉色"
+	}
+}
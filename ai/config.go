@@ -0,0 +1,142 @@
+package ai
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"owlllovo/ginessential/common"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ProviderConfig 对应 application.yml 中 ai.providers 下的一项
+type ProviderConfig struct {
+	Name      string        `mapstructure:"-"`
+	Type      string        `mapstructure:"type"`
+	BaseURL   string        `mapstructure:"baseUrl"`
+	Model     string        `mapstructure:"model"`
+	APIKey    string        `mapstructure:"apiKey"`
+	APIKeyEnv string        `mapstructure:"apiKeyEnv"`
+	MaxTokens int           `mapstructure:"maxTokens"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+	Reply     string        `mapstructure:"reply"`
+	// 每 1000 个 token 的价格，用于估算费用
+	PromptPrice     float64 `mapstructure:"promptPrice"`
+	CompletionPrice float64 `mapstructure:"completionPrice"`
+}
+
+// Key 返回 provider 的 API key，优先使用环境变量
+func (c ProviderConfig) Key() string {
+	if c.APIKeyEnv != "" {
+		if key := os.Getenv(c.APIKeyEnv); key != "" {
+			return key
+		}
+	}
+	return c.APIKey
+}
+
+// Tokens 返回单次请求的 max_tokens，未配置时使用 config.json 中的 MaxTokens
+func (c ProviderConfig) Tokens() int {
+	if c.MaxTokens > 0 {
+		return c.MaxTokens
+	}
+	return common.AppConfig.MaxTokens
+}
+
+// Config 对应 application.yml 中的 ai 节点
+type Config struct {
+	Provider  string                    `mapstructure:"provider"`
+	Fallback  []string                  `mapstructure:"fallback"`
+	Providers map[string]ProviderConfig `mapstructure:"providers"`
+	// LocalFallback 为 true 时，在 failover 链最后追加本地离线分析器，默认开启
+	LocalFallback *bool `mapstructure:"localFallback"`
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultCritic Critic
+	providers     = map[string]Critic{}
+)
+
+// InitCritics 读取 ai 配置，创建所有 provider 并设置默认的 Critic
+func InitCritics() error {
+	var cfg Config
+	if err := viper.UnmarshalKey("ai", &cfg); err != nil {
+		return err
+	}
+	if cfg.Provider == "" {
+		cfg.Provider = "openai"
+	}
+	if len(cfg.Providers) == 0 {
+		cfg.Providers = map[string]ProviderConfig{
+			"openai": {Type: "openai"},
+		}
+	}
+
+	built := map[string]Critic{}
+	for name, pc := range cfg.Providers {
+		pc.Name = name
+		if pc.Type == "" {
+			pc.Type = name
+		}
+		critic, err := New(pc)
+		if err != nil {
+			return err
+		}
+		built[name] = critic
+	}
+
+	var chain []Critic
+	hasLocal := false
+	for _, name := range append([]string{cfg.Provider}, cfg.Fallback...) {
+		critic, ok := built[name]
+		if !ok {
+			return fmt.Errorf("ai: provider %q is not configured", name)
+		}
+		if _, ok := critic.(*Local); ok {
+			hasLocal = true
+		}
+		chain = append(chain, critic)
+	}
+	useLocal := !hasLocal && (cfg.LocalFallback == nil || *cfg.LocalFallback)
+	if useLocal {
+		local, _ := NewLocal(ProviderConfig{Name: "local", Type: "local"})
+		chain = append(chain, local)
+	}
+
+	defaultMu.Lock()
+	providers = built
+	defaultCritic = NewFailover(chain...)
+	defaultMu.Unlock()
+
+	log.Printf("AI provider: %s, fallback: %v, local fallback: %v", cfg.Provider, cfg.Fallback, useLocal)
+	return nil
+}
+
+// Default 返回当前部署配置的 Critic（含 failover）
+func Default() Critic {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	if defaultCritic == nil {
+		critic, _ := New(ProviderConfig{Name: "openai", Type: "openai"})
+		return critic
+	}
+	return defaultCritic
+}
+
+// Provider 按名称返回单个已配置的 provider
+func Provider(name string) (Critic, bool) {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	critic, ok := providers[name]
+	return critic, ok
+}
+
+// SetDefault 替换默认 Critic，主要用于测试时注入 fake provider
+func SetDefault(critic Critic) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultCritic = critic
+}
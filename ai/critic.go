@@ -0,0 +1,39 @@
+package ai
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoContent 表示 provider 正常返回但没有给出任何内容
+var ErrNoContent = errors.New("no AI comment received")
+
+const (
+	TaskChat      = ""
+	TaskCritique  = "critique"
+	TaskScreening = "screening"
+)
+
+// Request 描述一次点评请求：提示词加上可选的图片，以及按时间顺序排列的对话历史。
+// Task 说明请求的用途，远程模型从提示词中就能理解，不理解提示词的本地分析器靠它决定输出格式
+type Request struct {
+	Prompt  string
+	Image   []byte
+	History []Turn
+	Task    string
+}
+
+// Result 是 provider 返回的点评结果，Cost 按 provider 配置的单价估算
+type Result struct {
+	Content  string
+	Provider string
+	Model    string
+	Usage    Usage
+	Cost     float64
+}
+
+// Critic 是所有 AI 点评 provider 的统一接口
+type Critic interface {
+	Name() string
+	Critique(ctx context.Context, req Request) (*Result, error)
+}
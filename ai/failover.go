@@ -0,0 +1,44 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+)
+
+// Failover 依次尝试多个 provider，直到有一个成功
+type Failover struct {
+	critics []Critic
+}
+
+func NewFailover(critics ...Critic) Critic {
+	if len(critics) == 1 {
+		return critics[0]
+	}
+	return &Failover{critics: critics}
+}
+
+func (f *Failover) Name() string {
+	names := make([]string, 0, len(f.critics))
+	for _, c := range f.critics {
+		names = append(names, c.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+func (f *Failover) Critique(ctx context.Context, req Request) (*Result, error) {
+	var errs []error
+	for _, c := range f.critics {
+		result, err := c.Critique(ctx, req)
+		if err == nil {
+			return result, nil
+		}
+		log.Printf("AI provider %s failed: %v", c.Name(), err)
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
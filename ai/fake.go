@@ -0,0 +1,52 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+)
+
+func init() {
+	Register("fake", NewFake)
+}
+
+// Fake 不访问网络，根据输入返回固定格式的点评，用于测试和本地开发
+type Fake struct {
+	cfg ProviderConfig
+}
+
+func NewFake(cfg ProviderConfig) (Critic, error) {
+	if cfg.Model == "" {
+		cfg.Model = "fake"
+	}
+	return &Fake{cfg: cfg}, nil
+}
+
+func (f *Fake) Name() string {
+	return f.cfg.Name
+}
+
+func (f *Fake) Critique(ctx context.Context, req Request) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	content := f.cfg.Reply
+	if content == "" {
+		content = fmt.Sprintf("fake critique for %q (image sha256 %x)", req.Prompt, sha256.Sum256(req.Image))
+	}
+	return f.cfg.result(req, content, "", nil), nil
+}
+
+// Stream 按字符逐段输出固定内容，便于在没有真实 provider 时调试流式接口
+func (f *Fake) Stream(ctx context.Context, req Request, onDelta DeltaFunc) (*Result, error) {
+	result, err := f.Critique(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range result.Content {
+		if err := onDelta(string(r)); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,48 @@
+package ai
+
+import "unicode"
+
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Turn 是对话历史中的一条消息
+type Turn struct {
+	Role    string
+	Content string
+}
+
+// EstimateTokens 粗略估算文本的 token 数：中日韩字符按 1 个 token，其它字符约 4 个一个 token
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// TrimHistory 从最新的消息往前保留，直到超出 budget 个 token；budget <= 0 时不保留历史
+func TrimHistory(history []Turn, budget int) []Turn {
+	used := 0
+	start := len(history)
+	for i := len(history) - 1; i >= 0; i-- {
+		// 每条消息额外算上角色等格式开销
+		cost := EstimateTokens(history[i].Content) + 4
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		start = i
+	}
+	return history[start:]
+}
+
+// historyFor 返回在 budget 内可以随 req 发送的历史
+func historyFor(req Request, budget int) []Turn {
+	return TrimHistory(req.History, budget-EstimateTokens(req.Prompt))
+}
@@ -0,0 +1,92 @@
+package ai
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"你好世界", 4},
+		{"画 a cat", 1 + 2},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTrimHistory(t *testing.T) {
+	// 每条消息的开销是 EstimateTokens + 4："aaaa" 为 5，40 个 b 为 14，"你好世界" 为 8
+	history := []Turn{
+		{Role: RoleUser, Content: "aaaa"},
+		{Role: RoleAssistant, Content: strings.Repeat("b", 40)},
+		{Role: RoleUser, Content: "你好世界"},
+		{Role: RoleAssistant, Content: "dddd"},
+	}
+	tests := []struct {
+		name    string
+		history []Turn
+		budget  int
+		want    []string
+	}{
+		{"no history", nil, 100, nil},
+		{"zero budget", history, 0, nil},
+		{"negative budget", history, -10, nil},
+		{"everything fits", history, 5 + 14 + 8 + 5, []string{"aaaa", strings.Repeat("b", 40), "你好世界", "dddd"}},
+		{"keeps the latest turns", history, 13, []string{"你好世界", "dddd"}},
+		{"latest turn alone", history, 12, []string{"dddd"}},
+		{"stops at the first turn that does not fit", history, 8 + 5 + 13, []string{"你好世界", "dddd"}},
+		{"latest turn too long", history, 4, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, turn := range TrimHistory(tt.history, tt.budget) {
+				got = append(got, turn.Content)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TrimHistory(budget %d) = %q, want %q", tt.budget, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVisualGLMHistory(t *testing.T) {
+	user := func(content string) Turn { return Turn{Role: RoleUser, Content: content} }
+	assistant := func(content string) Turn { return Turn{Role: RoleAssistant, Content: content} }
+
+	tests := []struct {
+		name    string
+		history []Turn
+		want    [][2]string
+	}{
+		{"empty", nil, [][2]string{}},
+		{"one exchange", []Turn{user("q"), assistant("a")}, [][2]string{{"q", "a"}}},
+		{"two exchanges", []Turn{user("q1"), assistant("a1"), user("q2"), assistant("a2")},
+			[][2]string{{"q1", "a1"}, {"q2", "a2"}}},
+		{"consecutive questions are joined", []Turn{user("q1"), user("q2"), assistant("a")},
+			[][2]string{{"q1\nq2", "a"}}},
+		{"consecutive answers are joined", []Turn{user("q"), assistant("a1"), assistant("a2")},
+			[][2]string{{"q", "a1\na2"}}},
+		{"unanswered question is dropped", []Turn{user("q1"), assistant("a1"), user("q2")},
+			[][2]string{{"q1", "a1"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := visualGLMHistory(tt.history); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("visualGLMHistory = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"strings"
+)
+
+func init() {
+	Register("local", NewLocal)
+}
+
+// Local 不调用任何模型，直接根据像素指标生成入门级的点评，在远程 provider 全部不可用时兜底
+type Local struct {
+	cfg ProviderConfig
+}
+
+func NewLocal(cfg ProviderConfig) (Critic, error) {
+	if cfg.Name == "" {
+		cfg.Name = "local"
+	}
+	if cfg.Model == "" {
+		cfg.Model = "local-metrics"
+	}
+	return &Local{cfg: cfg}, nil
+}
+
+func (l *Local) Name() string {
+	return l.cfg.Name
+}
+
+func (l *Local) Critique(ctx context.Context, req Request) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(req.Image) == 0 {
+		return nil, errors.New("local analyzer needs an image")
+	}
+	img, _, err := image.Decode(bytes.NewReader(req.Image))
+	if err != nil {
+		return nil, fmt.Errorf("local analyzer: %w", err)
+	}
+
+	report := newLocalReport(Analyze(img))
+	var content string
+	switch req.Task {
+	case TaskCritique:
+		content, err = report.json()
+	case TaskScreening:
+		// 像素指标无法判断内容是否合适，交给人工审核
+		content, err = marshalString(map[string]interface{}{
+			"verdict":    "review",
+			"confidence": 0,
+			"reasons":    []string{"AI 服务暂时不可用，离线分析无法判断内容是否合适"},
+		})
+	default:
+		content = report.text()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l.cfg.result(req, content, "", nil), nil
+}
+
+// localReport 是根据指标得出的评分和评语
+type localReport struct {
+	metrics     ImageMetrics
+	scores      map[string]int
+	summary     string
+	strengths   []string
+	suggestions []string
+}
+
+// score 把 0-1 的指标线性映射到 1-10 分
+func score(v float64) int {
+	return int(math.Round(1 + 9*math.Max(0, math.Min(1, v))))
+}
+
+// sweetSpot 在 [lo, hi] 区间内得 1，离区间越远越低
+func sweetSpot(v, lo, hi float64) float64 {
+	switch {
+	case v < lo:
+		return v / lo
+	case v > hi:
+		return math.Max(0, 1-(v-hi)/(1-hi))
+	default:
+		return 1
+	}
+}
+
+func newLocalReport(m ImageMetrics) *localReport {
+	r := &localReport{metrics: m}
+	coverage := sweetSpot(m.Coverage, 0.35, 0.9)
+	detail := sweetSpot(m.EdgeDensity, 0.04, 0.3)
+	balance := 0.5 + 0.5*sweetSpot(m.Symmetry, 0.55, 0.9)
+	r.scores = map[string]int{
+		"content":     score(0.6*coverage + 0.4*detail),
+		"composition": score(0.6*coverage + 0.4*balance),
+		"color":       score(0.6*m.ColorDiversity + 0.4*sweetSpot(m.Contrast, 0.2, 0.6)),
+		"technique":   score(0.5*detail + 0.5*sweetSpot(m.Contrast, 0.15, 0.6)),
+		"creativity":  score(0.5*m.ColorDiversity + 0.3*coverage + 0.2*(1-m.Symmetry)),
+	}
+
+	var colors []string
+	for _, c := range m.Palette {
+		if c.Name != "白色" && !contains(colors, c.Name) {
+			colors = append(colors, c.Name)
+		}
+	}
+
+	var summary strings.Builder
+	summary.WriteString("这幅画")
+	if len(colors) > 0 {
+		fmt.Fprintf(&summary, "主要用了%s", strings.Join(colors[:min(3, len(colors))], "、"))
+	}
+	switch {
+	case m.Brightness > 0.75:
+		summary.WriteString("，整体色调明亮轻快")
+	case m.Brightness < 0.35:
+		summary.WriteString("，整体色调比较深沉")
+	default:
+		summary.WriteString("，明暗比较适中")
+	}
+	fmt.Fprintf(&summary, "，画面大约%d%%的区域有内容。", int(math.Round(m.Coverage*100)))
+	summary.WriteString("（AI 老师暂时不在线，这是根据画面颜色和构图自动生成的参考点评。）")
+	r.summary = summary.String()
+
+	if m.ColorDiversity >= 0.5 {
+		r.strengths = append(r.strengths, "用色丰富大胆，画面很有活力")
+	}
+	if m.Coverage >= 0.35 && m.Coverage <= 0.9 {
+		r.strengths = append(r.strengths, "画面填得比较饱满，主体突出")
+	}
+	if m.Contrast >= 0.25 {
+		r.strengths = append(r.strengths, "明暗对比清楚，看起来很醒目")
+	}
+	if m.EdgeDensity >= 0.04 && m.EdgeDensity <= 0.3 {
+		r.strengths = append(r.strengths, "线条和细节刻画得很认真")
+	}
+	if m.Symmetry >= 0.8 {
+		r.strengths = append(r.strengths, "左右安排得很均衡，画面稳定")
+	}
+	if len(r.strengths) == 0 {
+		r.strengths = append(r.strengths, "敢于动笔表达自己的想法，这是最重要的一步")
+	}
+
+	if m.Coverage < 0.35 {
+		r.suggestions = append(r.suggestions, "画面留白有点多，可以把主体画大一些，或者给背景加上颜色")
+	}
+	if m.Coverage > 0.9 {
+		r.suggestions = append(r.suggestions, "画面有点满，可以适当留一些空白让主体更突出")
+	}
+	if m.ColorDiversity < 0.3 {
+		r.suggestions = append(r.suggestions, "可以试着多用几种颜色，比如加入一组冷暖对比色")
+	}
+	if m.Contrast < 0.15 {
+		r.suggestions = append(r.suggestions, "颜色深浅比较接近，可以用更深或更浅的颜色强调重点")
+	}
+	if m.EdgeDensity < 0.04 {
+		r.suggestions = append(r.suggestions, "可以多画一些细节，比如花纹、表情或者周围的小物件")
+	}
+	if m.EdgeDensity > 0.3 {
+		r.suggestions = append(r.suggestions, "线条比较多比较碎，可以试着用大块的颜色把形状概括出来")
+	}
+	if len(r.suggestions) == 0 {
+		r.suggestions = append(r.suggestions, "继续保持，下次可以尝试新的题材或画材")
+	}
+	return r
+}
+
+func contains(items []string, item string) bool {
+	for _, v := range items {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
+func marshalString(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// json 按结构化点评的格式输出，并附上原始指标
+func (r *localReport) json() (string, error) {
+	return marshalString(map[string]interface{}{
+		"scores":      r.scores,
+		"summary":     r.summary,
+		"strengths":   r.strengths,
+		"suggestions": r.suggestions,
+		"metrics":     r.metrics,
+	})
+}
+
+func (r *localReport) text() string {
+	var b strings.Builder
+	b.WriteString(r.summary)
+	b.WriteString("\n\n优点：")
+	for _, item := range r.strengths {
+		b.WriteString("\n- " + item)
+	}
+	b.WriteString("\n\n改进建议：")
+	for _, item := range r.suggestions {
+		b.WriteString("\n- " + item)
+	}
+	return b.String()
+}
@@ -0,0 +1,194 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+	defaultOpenAIModel   = "gpt-4-vision-preview"
+)
+
+func init() {
+	Register("openai", NewOpenAI)
+}
+
+// OpenAI 调用任意兼容 OpenAI chat/completions 接口的服务
+type OpenAI struct {
+	cfg    ProviderConfig
+	client *http.Client
+}
+
+func NewOpenAI(cfg ProviderConfig) (Critic, error) {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultOpenAIBaseURL
+	}
+	if cfg.Model == "" {
+		cfg.Model = defaultOpenAIModel
+	}
+	if cfg.APIKey == "" && cfg.APIKeyEnv == "" {
+		cfg.APIKeyEnv = "OPENAI_API_KEY"
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &OpenAI{cfg: cfg, client: newHTTPClient(cfg)}, nil
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAIContent struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content []openAIContent `json:"content"`
+}
+
+type openAIPayload struct {
+	Model     string          `json:"model"`
+	Messages  []openAIMessage `json:"messages"`
+	MaxTokens int             `json:"max_tokens,omitempty"`
+	Stream    bool            `json:"stream,omitempty"`
+	// StreamOptions 让流式响应在最后一个 chunk 中返回 usage
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+func (u *openAIUsage) usage() *Usage {
+	if u == nil {
+		return nil
+	}
+	return &Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+func (o *OpenAI) Name() string {
+	return o.cfg.Name
+}
+
+func (o *OpenAI) payload(req Request) openAIPayload {
+	content := []openAIContent{{Type: "text", Text: req.Prompt}}
+	if len(req.Image) > 0 {
+		content = append(content, openAIContent{
+			Type:     "image_url",
+			ImageURL: &openAIImageURL{URL: dataURL(req.Image)},
+		})
+	}
+
+	var messages []openAIMessage
+	for _, turn := range historyFor(req, o.cfg.Tokens()) {
+		messages = append(messages, openAIMessage{
+			Role:    turn.Role,
+			Content: []openAIContent{{Type: "text", Text: turn.Content}},
+		})
+	}
+	messages = append(messages, openAIMessage{Role: RoleUser, Content: content})
+
+	return openAIPayload{
+		Model:     o.cfg.Model,
+		Messages:  messages,
+		MaxTokens: o.cfg.Tokens(),
+	}
+}
+
+func (o *OpenAI) headers() map[string]string {
+	headers := map[string]string{}
+	if key := o.cfg.Key(); key != "" {
+		headers["Authorization"] = "Bearer " + key
+	}
+	return headers
+}
+
+func (o *OpenAI) Critique(ctx context.Context, req Request) (*Result, error) {
+	body, err := postJSON(ctx, o.client, o.cfg.BaseURL+"/chat/completions", o.headers(), o.payload(req))
+	if err != nil {
+		return nil, err
+	}
+
+	var response openAIResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Choices) == 0 || response.Choices[0].Message.Content == "" {
+		return nil, ErrNoContent
+	}
+
+	return o.cfg.result(req, response.Choices[0].Message.Content, response.Model, response.Usage.usage()), nil
+}
+
+type openAIStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+// Stream 使用 stream: true 请求，逐段解析 data: 行并回调 onDelta
+func (o *OpenAI) Stream(ctx context.Context, req Request, onDelta DeltaFunc) (*Result, error) {
+	payload := o.payload(req)
+	payload.Stream = true
+	payload.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+
+	resp, err := openStream(ctx, o.client, o.cfg.BaseURL+"/chat/completions", o.headers(), payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var usage *Usage
+	model := o.cfg.Model
+	err = readSSE(resp.Body, func(data string) error {
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return err
+		}
+		if chunk.Model != "" {
+			model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage.usage()
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			return nil
+		}
+		delta := chunk.Choices[0].Delta.Content
+		content.WriteString(delta)
+		return onDelta(delta)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if content.Len() == 0 {
+		return nil, ErrNoContent
+	}
+
+	return o.cfg.result(req, content.String(), model, usage), nil
+}
@@ -0,0 +1,35 @@
+package ai
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Factory 根据配置创建一个 Critic
+type Factory func(cfg ProviderConfig) (Critic, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{}
+)
+
+// Register 注册一种 provider 类型，通常在 init 中调用
+func Register(kind string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if _, dup := factories[kind]; dup {
+		panic("ai: Register called twice for provider type " + kind)
+	}
+	factories[kind] = factory
+}
+
+// New 根据配置中的 type 创建对应的 Critic
+func New(cfg ProviderConfig) (Critic, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[cfg.Type]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("ai: unknown provider type %q (provider %q)", cfg.Type, cfg.Name)
+	}
+	return factory(cfg)
+}
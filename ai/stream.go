@@ -0,0 +1,52 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+// DeltaFunc 接收流式输出的每一段文本，返回错误会中止生成
+type DeltaFunc func(delta string) error
+
+// Streamer 是支持逐 token 输出的 provider 实现的可选接口
+type Streamer interface {
+	Stream(ctx context.Context, req Request, onDelta DeltaFunc) (*Result, error)
+}
+
+// Stream 以流式方式调用 critic；不支持流式的 provider 会在完成后一次性输出全部内容
+func Stream(ctx context.Context, critic Critic, req Request, onDelta DeltaFunc) (*Result, error) {
+	if streamer, ok := critic.(Streamer); ok {
+		return streamer.Stream(ctx, req, onDelta)
+	}
+
+	result, err := critic.Critique(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := onDelta(result.Content); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Stream 依次尝试各个 provider；一旦某个 provider 已经输出了内容，就不再切换
+func (f *Failover) Stream(ctx context.Context, req Request, onDelta DeltaFunc) (*Result, error) {
+	var errs []error
+	for _, c := range f.critics {
+		emitted := false
+		result, err := Stream(ctx, c, req, func(delta string) error {
+			emitted = true
+			return onDelta(delta)
+		})
+		if err == nil {
+			return result, nil
+		}
+		log.Printf("AI provider %s failed: %v", c.Name(), err)
+		errs = append(errs, err)
+		if emitted || ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,40 @@
+package ai
+
+// Usage 是一次调用消耗的 token 数；provider 没有返回用量时按文本长度估算，Estimated 为 true
+type Usage struct {
+	PromptTokens     int  `json:"prompt_tokens"`
+	CompletionTokens int  `json:"completion_tokens"`
+	Estimated        bool `json:"estimated"`
+}
+
+// imageTokens 是估算时一张图片计入的 token 数，参考 OpenAI 高清模式 512px 分块的费用
+const imageTokens = 765
+
+// estimateUsage 根据请求和回复内容估算用量
+func estimateUsage(req Request, content string) Usage {
+	prompt := EstimateTokens(req.Prompt)
+	for _, turn := range req.History {
+		prompt += EstimateTokens(turn.Content)
+	}
+	if len(req.Image) > 0 {
+		prompt += imageTokens
+	}
+	return Usage{PromptTokens: prompt, CompletionTokens: EstimateTokens(content), Estimated: true}
+}
+
+// Cost 按 promptPrice / completionPrice（每 1000 token 的价格）计算费用
+func (c ProviderConfig) Cost(usage Usage) float64 {
+	return (float64(usage.PromptTokens)*c.PromptPrice + float64(usage.CompletionTokens)*c.CompletionPrice) / 1000
+}
+
+// result 组装 Result 并计算费用；usage 为空时按请求估算
+func (c ProviderConfig) result(req Request, content, model string, usage *Usage) *Result {
+	u := estimateUsage(req, content)
+	if usage != nil && (usage.PromptTokens > 0 || usage.CompletionTokens > 0) {
+		u = *usage
+	}
+	if model == "" {
+		model = c.Model
+	}
+	return &Result{Content: content, Provider: c.Name, Model: model, Usage: u, Cost: c.Cost(u)}
+}
@@ -0,0 +1,91 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+const defaultVisualGLMURL = "http://127.0.0.1:8080"
+
+func init() {
+	Register("visualglm", NewVisualGLM)
+}
+
+// VisualGLM 调用本地部署的 VisualGLM-6B api 服务
+type VisualGLM struct {
+	cfg    ProviderConfig
+	client *http.Client
+}
+
+func NewVisualGLM(cfg ProviderConfig) (Critic, error) {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultVisualGLMURL
+	}
+	if cfg.Model == "" {
+		cfg.Model = "visualglm-6b"
+	}
+	return &VisualGLM{cfg: cfg, client: newHTTPClient(cfg)}, nil
+}
+
+// visualGLMPayload 对应 VisualGLM api.py 的请求体，history 是 [问, 答] 对的列表
+type visualGLMPayload struct {
+	Image   string      `json:"image"`
+	Text    string      `json:"text"`
+	History [][2]string `json:"history"`
+}
+
+func (v *VisualGLM) Name() string {
+	return v.cfg.Name
+}
+
+func (v *VisualGLM) Critique(ctx context.Context, req Request) (*Result, error) {
+	payload := visualGLMPayload{
+		Image:   base64.StdEncoding.EncodeToString(req.Image),
+		Text:    req.Prompt,
+		History: visualGLMHistory(historyFor(req, v.cfg.Tokens())),
+	}
+
+	body, err := postJSON(ctx, v.client, v.cfg.BaseURL, nil, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	content := strings.TrimSpace(string(body))
+	if content == "" {
+		return nil, ErrNoContent
+	}
+	return v.cfg.result(req, content, "", nil), nil
+}
+
+// visualGLMHistory 把按角色排列的历史合并成 [问, 答] 对，连续的同角色消息会被拼接
+func visualGLMHistory(history []Turn) [][2]string {
+	pairs := [][2]string{}
+	var current [2]string
+	hasQuery := false
+	for _, turn := range history {
+		if turn.Role == RoleAssistant {
+			if current[1] != "" {
+				current[1] += "\n"
+			}
+			current[1] += turn.Content
+			continue
+		}
+		if current[1] != "" {
+			pairs = append(pairs, current)
+			current = [2]string{}
+			hasQuery = false
+		}
+		if hasQuery {
+			current[0] += "\n"
+		}
+		current[0] += turn.Content
+		hasQuery = true
+	}
+	// 没有回答的提问不能作为历史
+	if current[1] != "" {
+		pairs = append(pairs, current)
+	}
+	return pairs
+}
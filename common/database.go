@@ -30,12 +30,15 @@ func InitDB() *gorm.DB {
 		charset,
 		url.QueryEscape(loc))
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		panic("failed to connect database, err: " + err.Error())
 	}
-	db.AutoMigrate(&model.User{}, &model.Chat{}, &model.Message{})
+	db.AutoMigrate(&model.User{}, &model.Chat{}, &model.Message{}, &model.RefreshToken{}, &model.RevokedToken{}, &model.WebSocketTicket{}, &model.Permission{}, &model.Role{})
 	DB = db
+	if err := SeedRoles(db); err != nil {
+		panic("failed to seed roles, err: " + err.Error())
+	}
 	return db
 }
 
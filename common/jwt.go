@@ -5,20 +5,32 @@ import (
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
 )
 
-var jwtKey = []byte("a_secret_crect")
-
 type Claims struct {
-	UserId uint
+	UserId    uint
+	SessionId string `json:"sid,omitempty"`
 	jwt.StandardClaims
 }
 
-func ReleaseToken(user model.User) (string, error) {
-	expirationTime := time.Now().Add(7 * 24 * time.Hour)
+// AccessTokenTTL 返回 access token 的有效期，默认 15 分钟
+func AccessTokenTTL() time.Duration {
+	if ttl := viper.GetDuration("jwt.accessTTL"); ttl > 0 {
+		return ttl
+	}
+	return 15 * time.Minute
+}
+
+// ReleaseToken 为某次登录会话签发短期 access token，每个 token 都有唯一的 jti 以便吊销
+func ReleaseToken(user model.User, sessionId string) (string, error) {
+	expirationTime := time.Now().Add(AccessTokenTTL())
 	claims := &Claims{
-		UserId: user.ID,
+		UserId:    user.ID,
+		SessionId: sessionId,
 		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewV4().String(),
 			ExpiresAt: expirationTime.Unix(),
 			IssuedAt:  time.Now().Unix(),
 			Issuer:    "owlllovo",
@@ -26,8 +38,14 @@ func ReleaseToken(user model.User) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	key, err := currentSigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	tokenString, err := token.SignedString(key.signKey)
 
 	if err != nil {
 		return "", err
@@ -39,9 +57,7 @@ func ReleaseToken(user model.User) (string, error) {
 func ParseToken(tokenString string) (*jwt.Token, *Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (i interface{}, err error) {
-		return jwtKey, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey)
 
 	return token, claims, err
 }
@@ -0,0 +1,302 @@
+package common
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/spf13/viper"
+)
+
+// JWTKeyConfig 对应 application.yml 中 jwt.keys 下的一项
+type JWTKeyConfig struct {
+	Kid            string `mapstructure:"kid"`
+	Alg            string `mapstructure:"alg"`
+	SecretEnv      string `mapstructure:"secretEnv"`
+	SecretFile     string `mapstructure:"secretFile"`
+	PrivateKeyFile string `mapstructure:"privateKeyFile"`
+	PublicKeyFile  string `mapstructure:"publicKeyFile"`
+}
+
+// signingKey 是密钥环中的一把密钥；只有公钥的密钥只能用于校验轮换前签发的 token
+type signingKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+	publicKey crypto.PublicKey
+}
+
+var (
+	keysMu    sync.RWMutex
+	keyRing   = map[string]*signingKey{}
+	activeKey *signingKey
+)
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+// InitJWTKeys 从 jwt.keys 加载密钥环，jwt.activeKey 指定用于签发新 token 的密钥
+func InitJWTKeys() error {
+	var configs []JWTKeyConfig
+	if err := viper.UnmarshalKey("jwt.keys", &configs); err != nil {
+		return err
+	}
+	if len(configs) == 0 {
+		return errors.New("jwt: no signing keys configured (jwt.keys)")
+	}
+
+	ring := map[string]*signingKey{}
+	for _, cfg := range configs {
+		key, err := loadSigningKey(cfg)
+		if err != nil {
+			return err
+		}
+		if _, dup := ring[key.kid]; dup {
+			return fmt.Errorf("jwt: duplicate key id %q", key.kid)
+		}
+		ring[key.kid] = key
+	}
+
+	activeKid := viper.GetString("jwt.activeKey")
+	if activeKid == "" {
+		activeKid = configs[0].Kid
+	}
+	active, ok := ring[activeKid]
+	if !ok {
+		return fmt.Errorf("jwt: active key %q is not configured", activeKid)
+	}
+	if active.signKey == nil {
+		return fmt.Errorf("jwt: active key %q has no private key", activeKid)
+	}
+
+	keysMu.Lock()
+	keyRing = ring
+	activeKey = active
+	keysMu.Unlock()
+
+	log.Printf("JWT signing with key %s (%s), %d keys accepted", active.kid, active.method.Alg(), len(ring))
+	return nil
+}
+
+func loadSigningKey(cfg JWTKeyConfig) (*signingKey, error) {
+	if cfg.Kid == "" {
+		return nil, errors.New("jwt: every key needs a kid")
+	}
+	key := &signingKey{kid: cfg.Kid}
+
+	switch cfg.Alg {
+	case "HS256", "":
+		key.method = jwt.SigningMethodHS256
+		// 密钥只能来自环境变量或密钥文件，不允许写在配置文件里
+		var secret string
+		if cfg.SecretEnv != "" {
+			secret = os.Getenv(cfg.SecretEnv)
+		}
+		if secret == "" && cfg.SecretFile != "" {
+			content, err := os.ReadFile(cfg.SecretFile)
+			if err != nil {
+				return nil, fmt.Errorf("jwt: key %q: %w", cfg.Kid, err)
+			}
+			secret = strings.TrimSpace(string(content))
+		}
+		if secret == "" {
+			return nil, fmt.Errorf("jwt: key %q has no secret, set $%s or secretFile", cfg.Kid, cfg.SecretEnv)
+		}
+		key.signKey = []byte(secret)
+		key.verifyKey = []byte(secret)
+
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+		if cfg.PrivateKeyFile != "" {
+			pemBytes, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+			if err != nil {
+				return nil, fmt.Errorf("jwt: key %q: %w", cfg.Kid, err)
+			}
+			key.signKey = private
+			key.verifyKey = &private.PublicKey
+		} else if cfg.PublicKeyFile != "" {
+			pemBytes, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			public, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+			if err != nil {
+				return nil, fmt.Errorf("jwt: key %q: %w", cfg.Kid, err)
+			}
+			key.verifyKey = public
+		} else {
+			return nil, fmt.Errorf("jwt: key %q needs privateKeyFile or publicKeyFile", cfg.Kid)
+		}
+		key.publicKey = key.verifyKey
+
+	case "EdDSA":
+		key.method = SigningMethodEdDSA
+		if cfg.PrivateKeyFile != "" {
+			parsed, err := parsePEMKey(cfg.PrivateKeyFile, x509.ParsePKCS8PrivateKey)
+			if err != nil {
+				return nil, fmt.Errorf("jwt: key %q: %w", cfg.Kid, err)
+			}
+			private, ok := parsed.(ed25519.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("jwt: key %q is not an Ed25519 private key", cfg.Kid)
+			}
+			key.signKey = private
+			key.verifyKey = private.Public()
+		} else if cfg.PublicKeyFile != "" {
+			parsed, err := parsePEMKey(cfg.PublicKeyFile, x509.ParsePKIXPublicKey)
+			if err != nil {
+				return nil, fmt.Errorf("jwt: key %q: %w", cfg.Kid, err)
+			}
+			public, ok := parsed.(ed25519.PublicKey)
+			if !ok {
+				return nil, fmt.Errorf("jwt: key %q is not an Ed25519 public key", cfg.Kid)
+			}
+			key.verifyKey = public
+		} else {
+			return nil, fmt.Errorf("jwt: key %q needs privateKeyFile or publicKeyFile", cfg.Kid)
+		}
+		key.publicKey = key.verifyKey
+
+	default:
+		return nil, fmt.Errorf("jwt: key %q uses unsupported alg %q", cfg.Kid, cfg.Alg)
+	}
+
+	return key, nil
+}
+
+func parsePEMKey(path string, parse func([]byte) (interface{}, error)) (interface{}, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("invalid PEM file " + path)
+	}
+	return parse(block.Bytes)
+}
+
+func currentSigningKey() (*signingKey, error) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	if activeKey == nil {
+		return nil, errors.New("jwt: signing keys are not initialized")
+	}
+	return activeKey, nil
+}
+
+// verificationKey 是 jwt.Keyfunc：按 header 中的 kid 选择密钥，并要求 alg 与该密钥一致
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	kid, _ := token.Header["kid"].(string)
+	key := keyRing[kid]
+	if kid == "" {
+		// 没有 kid 的旧 token 使用当前密钥校验
+		key = activeKey
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %q for key %q", token.Method.Alg(), key.kid)
+	}
+	return key.verifyKey, nil
+}
+
+// JWK 是 JSON Web Key 中与 RSA 和 Ed25519 公钥相关的字段
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS 返回所有非对称密钥的公钥，HS256 密钥不会公开
+func JWKS() []JWK {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	keys := []JWK{}
+	for _, key := range keyRing {
+		switch public := key.publicKey.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "RSA",
+				Kid: key.kid,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "OKP",
+				Kid: key.kid,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Kid < keys[j].Kid })
+	return keys
+}
+
+// signingMethodEdDSA 为 jwt-go 增加 Ed25519 签名（RFC 8037）
+type signingMethodEdDSA struct{}
+
+var SigningMethodEdDSA jwt.SigningMethod = &signingMethodEdDSA{}
+
+func (m *signingMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(private, []byte(signingString))), nil
+}
+
+func (m *signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(public, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package common
+
+import (
+	"log"
+	"owlllovo/ginessential/model"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultPermissions 是系统内置的权限及说明
+var DefaultPermissions = []model.Permission{
+	{Name: model.PermPostApprove, Description: "Approve posts waiting for review"},
+	{Name: model.PermPostEditAny, Description: "Edit posts of other users"},
+	{Name: model.PermPostDeleteAny, Description: "Delete posts of other users"},
+	{Name: model.PermUserManage, Description: "Create, update and delete users"},
+	{Name: model.PermRoleManage, Description: "Manage roles and their permissions"},
+	{Name: model.PermCategoryWrite, Description: "Create, update and delete categories"},
+	{Name: model.PermPromptManage, Description: "Edit AI critique prompt templates"},
+	{Name: model.PermAIUsage, Description: "View AI usage reports and manage AI quotas"},
+}
+
+// defaultRoles 是首次启动时创建的角色；已存在的角色不会被覆盖，管理员的修改会保留
+var defaultRoles = []struct {
+	Name        string
+	Description string
+	Permissions []string
+}{
+	{Name: model.RoleModerator, Description: "Manages categories and reviews posts", Permissions: []string{model.PermCategoryWrite, model.PermPostApprove, model.PermPromptManage}},
+	{Name: model.RoleUser, Description: "Regular user"},
+	{Name: model.RoleAI, Description: "AI critic account"},
+}
+
+const permissionCacheTTL = time.Minute
+
+var (
+	permMu       sync.RWMutex
+	permCache    map[string]map[string]bool
+	permLoadedAt time.Time
+)
+
+// SeedRoles 创建内置权限和角色；Admin 角色总是拥有全部权限
+func SeedRoles(db *gorm.DB) error {
+	for _, permission := range DefaultPermissions {
+		p := permission
+		if err := db.Where("name = ?", p.Name).Attrs(model.Permission{Description: p.Description}).FirstOrCreate(&p).Error; err != nil {
+			return err
+		}
+	}
+
+	var all []model.Permission
+	if err := db.Find(&all).Error; err != nil {
+		return err
+	}
+	var admin model.Role
+	if err := db.Where("name = ?", model.RoleAdmin).Attrs(model.Role{Description: "Administrator"}).FirstOrCreate(&admin).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&admin).Association("Permissions").Append(all); err != nil {
+		return err
+	}
+
+	for _, r := range defaultRoles {
+		var role model.Role
+		result := db.Where("name = ?", r.Name).Limit(1).Find(&role)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			continue
+		}
+		role = model.Role{Name: r.Name, Description: r.Description}
+		if len(r.Permissions) > 0 {
+			if err := db.Where("name IN ?", r.Permissions).Find(&role.Permissions).Error; err != nil {
+				return err
+			}
+		}
+		if err := db.Create(&role).Error; err != nil {
+			return err
+		}
+	}
+
+	// 早期 UpdateUser 默认写入小写的 user
+	if err := db.Model(&model.User{}).Where("role = ?", "user").Update("role", model.RoleUser).Error; err != nil {
+		return err
+	}
+
+	InvalidatePermissions()
+	return nil
+}
+
+// InvalidatePermissions 清空角色权限缓存，角色被修改后调用
+func InvalidatePermissions() {
+	permMu.Lock()
+	defer permMu.Unlock()
+	permCache = nil
+}
+
+func loadPermissions() map[string]map[string]bool {
+	permMu.RLock()
+	cache, loadedAt := permCache, permLoadedAt
+	permMu.RUnlock()
+	if cache != nil && time.Since(loadedAt) < permissionCacheTTL {
+		return cache
+	}
+
+	var roles []model.Role
+	if err := GetDB().Preload("Permissions").Find(&roles).Error; err != nil {
+		log.Printf("Failed to load role permissions: %v", err)
+		if cache != nil {
+			return cache
+		}
+		return map[string]map[string]bool{}
+	}
+
+	cache = map[string]map[string]bool{}
+	for _, role := range roles {
+		perms := map[string]bool{}
+		for _, p := range role.Permissions {
+			perms[p.Name] = true
+		}
+		cache[strings.ToLower(role.Name)] = perms
+	}
+
+	permMu.Lock()
+	permCache, permLoadedAt = cache, time.Now()
+	permMu.Unlock()
+	return cache
+}
+
+// HasPermission 判断角色是否拥有权限，角色名不区分大小写
+func HasPermission(role, permission string) bool {
+	return loadPermissions()[strings.ToLower(role)][permission]
+}
+
+// RolePermissions 返回角色拥有的全部权限名称
+func RolePermissions(role string) []string {
+	perms := []string{}
+	for name := range loadPermissions()[strings.ToLower(role)] {
+		perms = append(perms, name)
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// RoleExists 判断角色是否存在，返回数据库中的规范名称
+func RoleExists(role string) (string, bool) {
+	var r model.Role
+	result := GetDB().Where("LOWER(name) = LOWER(?)", role).Limit(1).Find(&r)
+	return r.Name, result.Error == nil && result.RowsAffected > 0
+}
@@ -0,0 +1,249 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"owlllovo/ginessential/model"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, session revoked")
+)
+
+// TokenPair 是登录或刷新后返回给客户端的令牌
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// RefreshTokenTTL 返回 refresh token 的有效期，默认 30 天
+func RefreshTokenTTL() time.Duration {
+	if ttl := viper.GetDuration("jwt.refreshTTL"); ttl > 0 {
+		return ttl
+	}
+	return 30 * 24 * time.Hour
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// issuePair 在 sessionId 会话下签发新的 access token 和 refresh token
+func issuePair(db *gorm.DB, user model.User, sessionId string) (*TokenPair, *model.RefreshToken, error) {
+	refresh, err := newRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	record := model.RefreshToken{
+		UserID:    user.ID,
+		SessionID: sessionId,
+		TokenHash: hashToken(refresh),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL()),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return nil, nil, err
+	}
+
+	access, err := ReleaseToken(user, sessionId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(AccessTokenTTL().Seconds()),
+	}, &record, nil
+}
+
+// IssueTokens 为一次新的登录创建会话并签发令牌
+func IssueTokens(user model.User) (*TokenPair, error) {
+	pair, _, err := issuePair(GetDB(), user, uuid.NewV4().String())
+	return pair, err
+}
+
+// RefreshTokens 轮换 refresh token：旧令牌作废并签发新令牌；已作废的令牌被再次使用时吊销整个会话
+func RefreshTokens(refreshToken string) (*TokenPair, *model.User, error) {
+	db := GetDB()
+
+	var record model.RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(refreshToken)).First(&record).Error; err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if record.RevokedAt != nil {
+		if record.ReplacedBy != 0 {
+			// 已经轮换过的令牌又被使用，说明令牌可能被盗
+			log.Printf("Refresh token reuse detected for user %d session %s", record.UserID, record.SessionID)
+			if err := RevokeSession(record.SessionID); err != nil {
+				log.Printf("Failed to revoke session %s: %v", record.SessionID, err)
+			}
+			return nil, nil, ErrRefreshTokenReused
+		}
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	var user model.User
+	if err := db.First(&user, record.UserID).Error; err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if user.TokensValidAfter != nil && record.CreatedAt.Before(*user.TokensValidAfter) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	var pair *TokenPair
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 条件更新保证并发刷新时只有一个请求成功
+		now := time.Now()
+		result := tx.Model(&model.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", record.ID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidRefreshToken
+		}
+
+		var next *model.RefreshToken
+		var err error
+		pair, next, err = issuePair(tx, user, record.SessionID)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.RefreshToken{}).Where("id = ?", record.ID).Update("replaced_by", next.ID).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, &user, nil
+}
+
+// RevokeAccessToken 把 access token 的 jti 加入吊销列表，直到它自然过期
+func RevokeAccessToken(claims *Claims) error {
+	if claims.Id == "" {
+		return nil
+	}
+	return addRevoked(claims.Id, time.Unix(claims.ExpiresAt, 0))
+}
+
+// RevokeSession 作废会话的所有 refresh token，并让该会话已签发的 access token 失效
+func RevokeSession(sessionId string) error {
+	if sessionId == "" {
+		return nil
+	}
+	db := GetDB()
+	if err := db.Model(&model.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionId).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return addRevoked(sessionId, time.Now().Add(AccessTokenTTL()))
+}
+
+// RevokeAllSessions 让用户在所有设备上退出登录
+func RevokeAllSessions(userId uint) error {
+	db := GetDB()
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("id = ?", userId).Update("tokens_valid_after", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userId).
+			Update("revoked_at", now).Error
+	})
+}
+
+// RevokeSessionByRefreshToken 根据 refresh token 找到并吊销它所属的会话
+func RevokeSessionByRefreshToken(refreshToken string, userId uint) error {
+	var record model.RefreshToken
+	if err := GetDB().Where("token_hash = ? AND user_id = ?", hashToken(refreshToken), userId).First(&record).Error; err != nil {
+		return ErrInvalidRefreshToken
+	}
+	return RevokeSession(record.SessionID)
+}
+
+func addRevoked(id string, expiresAt time.Time) error {
+	return GetDB().Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.RevokedToken{ID: id, ExpiresAt: expiresAt}).Error
+}
+
+// sessionCreatedBefore 判断会话是否在 t 之前创建，查不到会话时按已失效处理
+func sessionCreatedBefore(sessionId string, t time.Time) bool {
+	if sessionId == "" {
+		return true
+	}
+	var first model.RefreshToken
+	if err := GetDB().Where("session_id = ?", sessionId).Order("created_at").First(&first).Error; err != nil {
+		return true
+	}
+	return first.CreatedAt.Before(t)
+}
+
+// IsTokenRevoked 检查 access token 是否被单独吊销、所属会话被吊销，或早于用户的"退出所有设备"时间
+func IsTokenRevoked(claims *Claims, user model.User) bool {
+	if user.TokensValidAfter != nil {
+		// iat 只精确到秒：早于截止时间所在秒的一定失效，同一秒内签发的按会话创建时间判断，
+		// 这样"退出所有设备"后立即重新登录拿到的 token 仍然有效
+		cutoff := user.TokensValidAfter.Truncate(time.Second).Unix()
+		if claims.IssuedAt < cutoff {
+			return true
+		}
+		if claims.IssuedAt == cutoff && sessionCreatedBefore(claims.SessionId, *user.TokensValidAfter) {
+			return true
+		}
+	}
+
+	ids := []string{}
+	if claims.Id != "" {
+		ids = append(ids, claims.Id)
+	}
+	if claims.SessionId != "" {
+		ids = append(ids, claims.SessionId)
+	}
+	if len(ids) == 0 {
+		return false
+	}
+
+	var count int64
+	if err := GetDB().Model(&model.RevokedToken{}).Where("id IN ?", ids).Count(&count).Error; err != nil {
+		log.Printf("Failed to check token revocation: %v", err)
+		return true
+	}
+	return count > 0
+}
+
+// PurgeExpiredTokens 定期删除已过期的吊销记录、refresh token 和未使用的 WebSocket 连接凭证
+func PurgeExpiredTokens(interval time.Duration) {
+	for {
+		now := time.Now()
+		db := GetDB()
+		db.Where("expires_at < ?", now).Delete(&model.RevokedToken{})
+		db.Where("expires_at < ?", now).Delete(&model.RefreshToken{})
+		db.Where("expires_at < ?", now).Delete(&model.WebSocketTicket{})
+		time.Sleep(interval)
+	}
+}
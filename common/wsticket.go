@@ -0,0 +1,61 @@
+package common
+
+import (
+	"errors"
+	"owlllovo/ginessential/model"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// WebSocketTicketTTL 是连接凭证的有效期，客户端拿到后应立即连接
+const WebSocketTicketTTL = 30 * time.Second
+
+var ErrInvalidWebSocketTicket = errors.New("invalid websocket ticket")
+
+// IssueWebSocketTicket 为已认证的 access token 签发一次性的 WebSocket 连接凭证
+func IssueWebSocketTicket(claims *Claims) (string, time.Time, error) {
+	ticket, err := newRefreshToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(WebSocketTicketTTL)
+	record := model.WebSocketTicket{
+		ID:        hashToken(ticket),
+		UserID:    claims.UserId,
+		SessionID: claims.SessionId,
+		TokenID:   claims.Id,
+		IssuedAt:  claims.IssuedAt,
+		ExpiresAt: expiresAt,
+	}
+	if err := GetDB().Create(&record).Error; err != nil {
+		return "", time.Time{}, err
+	}
+	return ticket, expiresAt, nil
+}
+
+// ConsumeWebSocketTicket 使用并删除连接凭证，返回换取凭证的 access token 的 claims，
+// 之后可以用 IsTokenRevoked 检查这个 token 所属的会话是否已被吊销
+func ConsumeWebSocketTicket(ticket string) (*Claims, error) {
+	if ticket == "" {
+		return nil, ErrInvalidWebSocketTicket
+	}
+	db := GetDB()
+	var record model.WebSocketTicket
+	if err := db.Where("id = ?", hashToken(ticket)).First(&record).Error; err != nil {
+		return nil, ErrInvalidWebSocketTicket
+	}
+	// 条件删除保证同一个凭证只能使用一次
+	result := db.Where("id = ? AND expires_at > ?", record.ID, time.Now()).Delete(&model.WebSocketTicket{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, ErrInvalidWebSocketTicket
+	}
+	return &Claims{
+		UserId:    record.UserID,
+		SessionId: record.SessionID,
+		StandardClaims: jwt.StandardClaims{
+			Id:       record.TokenID,
+			IssuedAt: record.IssuedAt,
+		},
+	}, nil
+}
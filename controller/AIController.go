@@ -1,207 +1,277 @@
 package controller
 
 import (
-	"bytes"
-	"encoding/base64"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"net/http"
-	"os"
+	"owlllovo/ginessential/ai"
 	"owlllovo/ginessential/common"
-	"path/filepath"
+	"owlllovo/ginessential/model"
+	"owlllovo/ginessential/queue"
+	"owlllovo/ginessential/storage"
+
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
 )
 
-func EncodeImageToBase64(imagePath string) (string, error) {
-	// 读取图片文件内容
-	imageBytes, err := ioutil.ReadFile(imagePath)
-	if err != nil {
-		return "", err
+// readPostImage 从存储后端读取帖子图片
+func readPostImage(imageFilename string) ([]byte, error) {
+	if imageFilename == "" {
+		return nil, nil
 	}
-
-	// 将图片内容编码为base64字符串
-	base64Image := base64.StdEncoding.EncodeToString(imageBytes)
-	return base64Image, nil
+	return storage.ReadAll(context.Background(), storage.Default(), imageFilename)
 }
 
-type Config struct {
-	MaxTokens int `json:"MaxTokens"`
+// GetAIComment 使用 application.yml 中配置的 AI provider（含 failover）对图片进行点评，用量记在 meta 名下
+func GetAIComment(imageFilename, promptText string, meta usageMeta) (string, error) {
+	return GetAIReply(imageFilename, promptText, nil, meta)
 }
 
-func GetGPTComment(imageFilename, promptText string) (string, error) {
-	log.Println("Running GetGPTComment for image:", imageFilename, "with prompt:", promptText)
-	imagePath := filepath.Join("assets", "images", imageFilename)
-	imageBase64, err := EncodeImageToBase64(imagePath)
+// GetAIReply 与 GetAIComment 相同，但会带上之前的对话历史
+func GetAIReply(imageFilename, promptText string, history []ai.Turn, meta usageMeta) (string, error) {
+	log.Println("Running GetAIReply for image:", imageFilename, "with prompt:", promptText)
+	image, err := readPostImage(imageFilename)
 	if err != nil {
-		log.Printf("Error encoding image to base64: %v", err)
+		log.Printf("Error reading image: %v", err)
 		return "", err
 	}
-	type ImageURL struct {
-		URL string `json:"url"`
-	}
-
-	type Content struct {
-		Type     string    `json:"type"`
-		Text     string    `json:"text,omitempty"`
-		ImageURL *ImageURL `json:"image_url,omitempty"`
-	}
 
-	type Message struct {
-		Role    string    `json:"role"`
-		Content []Content `json:"content"`
-	}
-
-	type Payload struct {
-		Model     string    `json:"model"`
-		Messages  []Message `json:"messages"`
-		MaxTokens int       `json:"max_tokens"`
+	result, err := meteredCritique(context.Background(), common.GetDB(), ai.Default(), ai.Request{
+		Prompt:  promptText,
+		Image:   image,
+		History: history,
+	}, meta)
+	if err != nil {
+		return "", err
 	}
 
-	// 使用配置中的 MaxTokens
-	fmt.Println("MaxTokens from config:", common.AppConfig.MaxTokens)
-
-	data := Payload{
-		Model: "gpt-4-vision-preview",
-		Messages: []Message{
-			{
-				Role: "user",
-				Content: []Content{
-					{
-						Type: "text",
-						Text: promptText,
-					},
-					{
-						Type:     "image_url",
-						ImageURL: &ImageURL{URL: "data:image/jpeg;base64," + imageBase64},
-					},
-				},
-			},
-		},
-		MaxTokens: common.AppConfig.MaxTokens,
-	}
+	log.Printf("AI comment generated by %s (%s)", result.Provider, result.Model)
+	return result.Content, nil
+}
 
-	payloadBytes, err := json.Marshal(data)
+// StreamAIReply 与 GetAIReply 相同，但会把生成过程中的每段文本交给 onDelta
+func StreamAIReply(ctx context.Context, imageFilename, promptText string, history []ai.Turn, meta usageMeta, onDelta ai.DeltaFunc) (string, error) {
+	log.Println("Running StreamAIReply for image:", imageFilename, "with prompt:", promptText)
+	image, err := readPostImage(imageFilename)
 	if err != nil {
-		log.Printf("Error marshaling data: %v", err)
+		log.Printf("Error reading image: %v", err)
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(payloadBytes))
+	result, err := meteredStream(ctx, common.GetDB(), ai.Default(), ai.Request{
+		Prompt:  promptText,
+		Image:   image,
+		History: history,
+	}, meta, onDelta)
 	if err != nil {
-		log.Printf("Error creating request: %v", err)
 		return "", err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+os.Getenv("OPENAI_API_KEY"))
+	log.Printf("AI comment streamed by %s (%s)", result.Provider, result.Model)
+	return result.Content, nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error making request: %v", err)
-		return "", err
-	}
-	defer resp.Body.Close()
+const (
+	JobPostAIComment = "post_ai_comment"
+	JobChatAIReply   = "chat_ai_reply"
+)
 
-	log.Printf("Response Status: %s", resp.Status)
+func init() {
+	queue.Register(JobPostAIComment, handlePostAIComment)
+	queue.Register(JobChatAIReply, handleChatAIReply)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Non-OK HTTP status: %d", resp.StatusCode)
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		log.Printf("Response Body: %s", string(bodyBytes))
-		return "", errors.New("API request failed")
-	}
+// PostAICommentJob 是 JobPostAIComment 任务的 payload，RequestedBy 是要求重新生成的用户，为 0 时用量记在作者名下
+type PostAICommentJob struct {
+	PostID           string `json:"post_id"`
+	Prompt           string `json:"prompt"`
+	PromptTemplateID uint   `json:"prompt_template_id"`
+	PromptVersion    int    `json:"prompt_version"`
+	RequestedBy      uint   `json:"requested_by"`
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Error reading response body: %v", err)
-		return "", err
+// ChatAIReplyJob 是 JobChatAIReply 任务的 payload，MessageID 是需要回复的用户消息，UserID 是发消息的用户
+type ChatAIReplyJob struct {
+	ChatID    string `json:"chat_id"`
+	PostID    string `json:"post_id"`
+	MessageID string `json:"message_id"`
+	AIUserID  uint   `json:"ai_user_id"`
+	UserID    uint   `json:"user_id"`
+	Content   string `json:"content"`
+}
+
+// chatHistory 按时间顺序返回 Chat 中早于 beforeMessageID 的消息，AI 发出的消息标记为 assistant
+func chatHistory(db *gorm.DB, chatID uuid.UUID, aiUserID uint, beforeMessageID uuid.UUID) ([]ai.Turn, error) {
+	var current model.Message
+	if err := db.Where("id = ?", beforeMessageID).First(&current).Error; err != nil {
+		return nil, err
 	}
 
-	type ResponseChoice struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
+	var messages []model.Message
+	if err := db.Where("chat_id = ? AND id <> ? AND created_at <= ?", chatID, current.ID, current.CreatedAt).
+		Order("created_at ASC").Find(&messages).Error; err != nil {
+		return nil, err
 	}
 
-	type Response struct {
-		Choices []ResponseChoice `json:"choices"`
+	history := make([]ai.Turn, 0, len(messages))
+	for _, message := range messages {
+		role := ai.RoleUser
+		if message.SenderID == aiUserID {
+			role = ai.RoleAssistant
+		}
+		history = append(history, ai.Turn{Role: role, Content: message.Content})
 	}
+	return history, nil
+}
 
-	var response Response
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		log.Printf("Error unmarshaling response: %v", err)
-		return "", err
+// ensureAIUser 返回 AI 账号，不存在时创建
+func ensureAIUser(db *gorm.DB) (model.User, error) {
+	var aiUser model.User
+	err := db.Where("name = ?", "GPT-4").FirstOrCreate(&aiUser, model.User{Name: "GPT-4", Role: model.RoleAI}).Error
+	return aiUser, err
+}
+
+func handlePostAIComment(job *model.Job) error {
+	var payload PostAICommentJob
+	if err := queue.Decode(job, &payload); err != nil {
+		return err
 	}
 
-	if len(response.Choices) > 0 && len(response.Choices[0].Message.Content) > 0 {
-		return response.Choices[0].Message.Content, nil
+	db := common.GetDB()
+	var post model.Post
+	if err := db.Where("id = ?", payload.PostID).First(&post).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Post %s was deleted before AI comment was generated", payload.PostID)
+			return nil
+		}
+		return err
 	}
 
-	return "", errors.New("no AI comment received")
-}
+	aiUser, err := ensureAIUser(db)
+	if err != nil {
+		return fmt.Errorf("failed to ensure AI user exists: %w", err)
+	}
 
-func GetVisualGLMComment(imageFilename, promptText string) (string, error) {
-	log.Println("Calling VisualGLM for image:", imageFilename, "with description:", promptText)
-	imagePath := filepath.Join("assets", "images", imageFilename)
-	imageBase64, err := EncodeImageToBase64(imagePath)
+	image, err := readPostImage(post.HeadImg)
 	if err != nil {
-		log.Printf("Error encoding image to base64: %v", err)
-		return "", err
+		return err
+	}
+	requester := payload.RequestedBy
+	if requester == 0 {
+		requester = post.UserId
+	}
+	// 入队时检查过限额，但排队期间可能已经用完，执行前再检查一次；超出限额的任务直接放弃
+	if err := checkAIQuotaForUser(db, requester); err != nil {
+		if _, ok := isQuotaError(err); ok {
+			log.Printf("Skipping AI critique for post %s: %v", post.ID, err)
+			return nil
+		}
+		return err
+	}
+	result, err := meteredCritique(context.Background(), db, ai.Default(), ai.Request{Prompt: payload.Prompt, Image: image, Task: ai.TaskCritique},
+		usageMeta{UserID: requester, PostID: post.ID, Purpose: model.UsagePostCritique})
+	if err != nil {
+		return err
 	}
+	log.Printf("AI comment generated by %s (%s)", result.Provider, result.Model)
 
-	// Prepare the JSON payload
-	type Payload struct {
-		Image   string   `json:"image"`
-		Text    string   `json:"text"`
-		History []string `json:"history"`
+	// 不支持 JSON 输出的 provider 仍然保存原始文字点评
+	comment := model.Comment{PostID: post.ID, UserID: aiUser.ID, Content: result.Content}
+	critique, err := parseCritique(result.Content)
+	if err != nil {
+		log.Printf("Post %s: %v, saving plain text comment", post.ID, err)
+		critique = &model.PostCritique{Summary: result.Content}
+	} else {
+		critique.Structured = true
+		comment.Content = critiqueText(critique)
 	}
 
-	data := Payload{
-		Image:   imageBase64,
-		Text:    promptText,
-		History: []string{}, // This could be populated if there's contextual history needed
+	critique.PostID = post.ID
+	critique.Provider = result.Provider
+	critique.Model = result.Model
+	critique.PromptTemplateID = payload.PromptTemplateID
+	critique.PromptVersion = payload.PromptVersion
+	return saveCritique(db, &comment, critique)
+}
+
+// saveCritique 保存 AI 评论和新版本的点评。(post_id, version) 有唯一索引，
+// 并发保存同一帖子的点评时版本号冲突的一方重新计算版本号，而不是重新调用模型
+func saveCritique(db *gorm.DB, comment *model.Comment, critique *model.PostCritique) error {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var latest int
+			if err := tx.Model(&model.PostCritique{}).Where("post_id = ?", critique.PostID).
+				Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(comment).Error; err != nil {
+				return err
+			}
+			critique.ID = 0
+			critique.CommentID = comment.ID
+			critique.Version = latest + 1
+			return tx.Create(critique).Error
+		})
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return err
+		}
 	}
+	return err
+}
 
-	payloadBytes, err := json.Marshal(data)
-	if err != nil {
-		log.Printf("Error marshaling data: %v", err)
-		return "", err
+func handleChatAIReply(job *model.Job) error {
+	var payload ChatAIReplyJob
+	if err := queue.Decode(job, &payload); err != nil {
+		return err
 	}
 
-	// Set up the HTTP request
-	req, err := http.NewRequest("POST", "http://127.0.0.1:8080", bytes.NewBuffer(payloadBytes))
+	chatID, err := uuid.FromString(payload.ChatID)
 	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		return "", err
+		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error making request: %v", err)
-		return "", err
+	db := common.GetDB()
+	var post model.Post
+	if err := db.First(&post, "id = ?", payload.PostID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Post %s was deleted before AI reply was generated", payload.PostID)
+			return nil
+		}
+		return err
 	}
-	defer resp.Body.Close()
 
-	log.Printf("Response Status: %s", resp.Status)
+	// 与帖子点评一样，排队期间限额可能已经用完，执行前再检查一次
+	if err := checkAIQuotaForUser(db, payload.UserID); err != nil {
+		if _, ok := isQuotaError(err); ok {
+			log.Printf("Skipping AI reply in chat %s: %v", chatID, err)
+			return nil
+		}
+		return err
+	}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		log.Printf("Response Body: %s", string(bodyBytes))
-		return "", fmt.Errorf("API request failed with status %d", resp.StatusCode)
+	var history []ai.Turn
+	if messageID, err := uuid.FromString(payload.MessageID); err == nil {
+		if history, err = chatHistory(db, chatID, payload.AIUserID, messageID); err != nil {
+			log.Printf("Failed to load chat history: %v", err)
+		}
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	aiComment, err := GetAIReply(post.HeadImg, payload.Content, history,
+		usageMeta{UserID: payload.UserID, PostID: post.ID, Purpose: model.UsageChatReply})
 	if err != nil {
-		log.Printf("Error reading response body: %v", err)
-		return "", err
+		return err
 	}
 
-	// Assume the response is plain text or modify this part if it's structured JSON
-	return string(body), nil
+	aiMessage := model.Message{
+		ChatID:   chatID,
+		SenderID: payload.AIUserID,
+		Content:  aiComment,
+	}
+	if err := db.Create(&aiMessage).Error; err != nil {
+		return err
+	}
+	broadcastMessage(db, aiMessage)
+	return nil
 }
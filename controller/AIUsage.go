@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"owlllovo/ginessential/ai"
+	"owlllovo/ginessential/model"
+	"owlllovo/ginessential/util"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// billablePurposes 是计入用户限额的调用；安全预审由系统发起，只记录不计入限额
+var billablePurposes = []string{model.UsageChatReply, model.UsagePostCritique}
+
+// usageMeta 说明一次 AI 调用是为谁、为哪个帖子、出于什么目的发起的
+type usageMeta struct {
+	UserID  uint
+	PostID  uuid.UUID
+	Purpose string
+}
+
+// QuotaError 表示用户的 AI 调用次数已达到限额
+type QuotaError struct {
+	Period string
+	Limit  int
+	Resets time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("AI %s quota of %d requests exceeded, resets at %s", e.Period, e.Limit, e.Resets.Format(time.RFC3339))
+}
+
+// AIQuotaLimits 是用户当前生效的限额，0 表示不限
+type AIQuotaLimits struct {
+	Daily   int `json:"daily"`
+	Monthly int `json:"monthly"`
+}
+
+// quotaLimits 依次查找用户单独设置的限额、角色限额（ai.quota.roles.<角色>）和默认限额（ai.quota.default）
+func quotaLimits(db *gorm.DB, user model.User) AIQuotaLimits {
+	var quota model.AIQuota
+	if err := db.Where("user_id = ?", user.ID).Limit(1).Find(&quota).Error; err == nil && quota.UserID != 0 {
+		return AIQuotaLimits{Daily: quota.Daily, Monthly: quota.Monthly}
+	}
+
+	key := "ai.quota.default"
+	if viper.IsSet("ai.quota.roles." + user.Role) {
+		key = "ai.quota.roles." + user.Role
+	}
+	return AIQuotaLimits{Daily: viper.GetInt(key + ".daily"), Monthly: viper.GetInt(key + ".monthly")}
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// countAIUsage 统计用户从 since 开始计入限额的成功调用次数
+func countAIUsage(db *gorm.DB, userID uint, since time.Time) (int64, error) {
+	var count int64
+	err := db.Model(&model.AIUsage{}).
+		Where("user_id = ? AND purpose IN ? AND error = '' AND created_at >= ?", userID, billablePurposes, since).
+		Count(&count).Error
+	return count, err
+}
+
+// checkAIQuota 在发起 AI 调用前检查限额，超出时返回 *QuotaError
+func checkAIQuota(db *gorm.DB, user model.User) error {
+	limits := quotaLimits(db, user)
+	now := time.Now()
+
+	if limits.Daily > 0 {
+		used, err := countAIUsage(db, user.ID, startOfDay(now))
+		if err != nil {
+			return err
+		}
+		if used >= int64(limits.Daily) {
+			return &QuotaError{Period: "daily", Limit: limits.Daily, Resets: startOfDay(now).AddDate(0, 0, 1)}
+		}
+	}
+	if limits.Monthly > 0 {
+		used, err := countAIUsage(db, user.ID, startOfMonth(now))
+		if err != nil {
+			return err
+		}
+		if used >= int64(limits.Monthly) {
+			return &QuotaError{Period: "monthly", Limit: limits.Monthly, Resets: startOfMonth(now).AddDate(0, 1, 0)}
+		}
+	}
+	return nil
+}
+
+// checkAIQuotaForUser 与 checkAIQuota 相同，但只知道用户 ID
+func checkAIQuotaForUser(db *gorm.DB, userID uint) error {
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return err
+	}
+	return checkAIQuota(db, user)
+}
+
+// recordAIUsage 保存一次调用的用量，记录失败只打印日志，不影响调用结果
+func recordAIUsage(db *gorm.DB, meta usageMeta, result *ai.Result, latency time.Duration, callErr error) {
+	usage := model.AIUsage{
+		UserID:    meta.UserID,
+		PostID:    meta.PostID,
+		Purpose:   meta.Purpose,
+		LatencyMs: latency.Milliseconds(),
+	}
+	if result != nil {
+		usage.Provider = result.Provider
+		usage.Model = result.Model
+		usage.PromptTokens = result.Usage.PromptTokens
+		usage.CompletionTokens = result.Usage.CompletionTokens
+		usage.Estimated = result.Usage.Estimated
+		usage.Cost = result.Cost
+	}
+	if callErr != nil {
+		usage.Error = util.Truncate(callErr.Error(), 255)
+	}
+	if err := db.Create(&usage).Error; err != nil {
+		log.Printf("Failed to record AI usage: %v", err)
+	}
+}
+
+// meteredCritique 调用 critic 并记录用量
+func meteredCritique(ctx context.Context, db *gorm.DB, critic ai.Critic, req ai.Request, meta usageMeta) (*ai.Result, error) {
+	start := time.Now()
+	result, err := critic.Critique(ctx, req)
+	recordAIUsage(db, meta, result, time.Since(start), err)
+	return result, err
+}
+
+// meteredStream 以流式方式调用 critic 并记录用量
+func meteredStream(ctx context.Context, db *gorm.DB, critic ai.Critic, req ai.Request, meta usageMeta, onDelta ai.DeltaFunc) (*ai.Result, error) {
+	start := time.Now()
+	result, err := ai.Stream(ctx, critic, req, onDelta)
+	recordAIUsage(db, meta, result, time.Since(start), err)
+	return result, err
+}
+
+// isQuotaError 判断错误是否为限额错误
+func isQuotaError(err error) (*QuotaError, bool) {
+	var quotaErr *QuotaError
+	ok := errors.As(err, &quotaErr)
+	return quotaErr, ok
+}
@@ -1,12 +1,20 @@
 package controller
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"owlllovo/ginessential/common"
 	"owlllovo/ginessential/model"
+	"owlllovo/ginessential/queue"
+	"owlllovo/ginessential/realtime"
 	"owlllovo/ginessential/response"
+	"owlllovo/ginessential/vo"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	uuid "github.com/satori/go.uuid"
@@ -23,11 +31,8 @@ func NewChatController() *ChatController {
 
 func (c *ChatController) SendMessage(ctx *gin.Context) {
 	sender, _ := ctx.Get("user")
-	var req struct {
-		ReceiverID uint   `json:"receiver_id"`
-		PostID     string `json:"post_id"`
-		Content    string `json:"content"`
-	}
+	senderID := sender.(model.User).ID
+	var req vo.SendMessageRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		response.Fail(ctx, nil, "Invalid request")
 		return
@@ -38,24 +43,25 @@ func (c *ChatController) SendMessage(ctx *gin.Context) {
 		response.Fail(ctx, nil, "Invalid post ID")
 		return
 	}
+	// 只能就自己可以查看的帖子发起聊天
+	if _, err := findVisiblePost(c.DB, currentUser(ctx), postID.String()); err != nil {
+		response.Fail(ctx, nil, "Post not found")
+		return
+	}
 
-	// 查找是否已存在相同收发者和帖子的 Chat
-	var chat model.Chat
-	err = c.DB.Where("((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)) AND post_id = ?",
-		sender.(model.User).ID, req.ReceiverID, req.ReceiverID, sender.(model.User).ID, postID).First(&chat).Error
+	// 检查接收者是否为"GPT-4"
+	var receiver model.User
+	if err := c.DB.First(&receiver, req.ReceiverID).Error; err != nil {
+		response.Fail(ctx, nil, "Receiver not found")
+		return
+	}
+	if receiver.Name == "GPT-4" && !c.checkQuota(ctx, sender.(model.User)) {
+		return
+	}
 
-	// 如果找不到，创建新的 Chat
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		chat = model.Chat{
-			SenderID:   sender.(model.User).ID,
-			ReceiverID: req.ReceiverID,
-			PostID:     postID,
-		}
-		if err := c.DB.Create(&chat).Error; err != nil {
-			response.Fail(ctx, nil, "Failed to create chat")
-			return
-		}
-	} else if err != nil {
+	chat, err := c.findOrCreateChat(senderID, req.ReceiverID, postID)
+	if err != nil {
+		log.Println(err)
 		response.Fail(ctx, nil, "Failed to retrieve chat")
 		return
 	}
@@ -63,7 +69,7 @@ func (c *ChatController) SendMessage(ctx *gin.Context) {
 	// 创建新的 Message 并关联到找到或创建的 Chat，同时确保 SenderID 被正确设置
 	message := model.Message{
 		ChatID:   chat.ID,
-		SenderID: sender.(model.User).ID, // 明确设置 SenderID
+		SenderID: senderID, // 明确设置 SenderID
 		Content:  req.Content,
 	}
 
@@ -71,47 +77,180 @@ func (c *ChatController) SendMessage(ctx *gin.Context) {
 		response.Fail(ctx, nil, "Failed to send message")
 		return
 	}
+	broadcastMessage(c.DB, message)
+
+	log.Println(req.PostID)
+	if receiver.Name == "GPT-4" {
+		// 如果接收者为"GPT-4"，则把 AI 回复放入任务队列，由 worker 异步生成
+		if _, err := queue.Enqueue(c.DB, JobChatAIReply, ChatAIReplyJob{
+			ChatID:    chat.ID.String(),
+			PostID:    postID.String(),
+			MessageID: message.ID.String(),
+			AIUserID:  receiver.ID,
+			UserID:    senderID,
+			Content:   req.Content,
+		}); err != nil {
+			log.Printf("Failed to enqueue AI reply: %v", err)
+			response.Fail(ctx, nil, "Failed to request AI reply")
+			return
+		}
+	}
+
+	// 由于 AI 回复是异步的，这里直接响应消息发送成功
+	response.Success(ctx, nil, "Message sent successfully")
+}
+
+// checkQuota 检查用户的 AI 限额，超出时返回 429 并给出重置时间
+func (c *ChatController) checkQuota(ctx *gin.Context, user model.User) bool {
+	err := checkAIQuota(c.DB, user)
+	if err == nil {
+		return true
+	}
+	if quotaErr, ok := isQuotaError(err); ok {
+		response.Response(ctx, http.StatusTooManyRequests, 429, gin.H{
+			"error":  quotaErr.Error(),
+			"period": quotaErr.Period,
+			"limit":  quotaErr.Limit,
+			"resets": quotaErr.Resets,
+		}, "AI quota exceeded")
+		return false
+	}
+	log.Printf("Failed to check AI quota: %v", err)
+	response.Fail(ctx, nil, "Failed to check AI quota")
+	return false
+}
+
+// findOrCreateChat 查找相同收发者和帖子的 Chat，找不到时创建新的 Chat
+func (c *ChatController) findOrCreateChat(senderID, receiverID uint, postID uuid.UUID) (model.Chat, error) {
+	var chat model.Chat
+	err := c.DB.Where("((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)) AND post_id = ?",
+		senderID, receiverID, receiverID, senderID, postID).First(&chat).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		chat = model.Chat{
+			SenderID:   senderID,
+			ReceiverID: receiverID,
+			PostID:     postID,
+		}
+		err = c.DB.Create(&chat).Error
+	}
+	return chat, err
+}
+
+// StreamMessage 发送消息给 AI 用户，并通过 Server-Sent Events 逐段返回 AI 的回复
+//
+// 事件依次为：message（已保存的用户消息）、delta（回复片段）、done（已保存的 AI 消息）或 error
+func (c *ChatController) StreamMessage(ctx *gin.Context) {
+	sender, _ := ctx.Get("user")
+	senderID := sender.(model.User).ID
+	var req vo.SendMessageRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Fail(ctx, nil, "Invalid request")
+		return
+	}
+
+	postID, err := uuid.FromString(req.PostID)
+	if err != nil {
+		response.Fail(ctx, nil, "Invalid post ID")
+		return
+	}
 
-	// 检查接收者是否为"GPT-4"
 	var receiver model.User
 	if err := c.DB.First(&receiver, req.ReceiverID).Error; err != nil {
 		response.Fail(ctx, nil, "Receiver not found")
 		return
 	}
-	log.Println(req.PostID)
-	if receiver.Name == "GPT-4" {
-		// 如果接收者为"GPT-4"，则在 goroutine 中异步调用 AI 函数
-		go func() {
-			// 获取帖子信息以便于获取图片地址
-			var post model.Post
-			postID, _ := uuid.FromString(req.PostID) // 已经在外部检查过了，这里可以不再检查错误
-			if err := c.DB.First(&post, "id = ?", postID).Error; err != nil {
-				log.Printf("Post not found: %v", err)
-				return
-			}
-
-			// 调用 AI 函数获取评论
-			aiComment, err := GetGPTComment(post.HeadImg, req.Content)
-			if err != nil {
-				log.Printf("AI Comment failed: %v", err)
-				return
-			}
-
-			// 创建 AI 回复消息并保存到数据库
-			aiMessage := model.Message{
-				ChatID:   chat.ID,
-				SenderID: receiver.ID, // 假设 receiver.ID 是 AI 用户的 ID
-				Content:  aiComment,
-			}
-			if err := c.DB.Create(&aiMessage).Error; err != nil {
-				log.Printf("Failed to send AI message: %v", err)
-				return
-			}
-		}()
+	if receiver.Name != "GPT-4" {
+		response.Fail(ctx, nil, "Streaming replies are only available when chatting with AI")
+		return
+	}
+	if !c.checkQuota(ctx, sender.(model.User)) {
+		return
 	}
 
-	// 由于 AI 回复是异步的，这里直接响应消息发送成功
-	response.Success(ctx, nil, "Message sent successfully")
+	// 帖子图片会发送给 AI，必须是发送者可以查看的帖子
+	post, err := findVisiblePost(c.DB, currentUser(ctx), postID.String())
+	if err != nil {
+		response.Fail(ctx, nil, "Post not found")
+		return
+	}
+
+	chat, err := c.findOrCreateChat(senderID, receiver.ID, postID)
+	if err != nil {
+		log.Println(err)
+		response.Fail(ctx, nil, "Failed to retrieve chat")
+		return
+	}
+
+	message := model.Message{
+		ChatID:   chat.ID,
+		SenderID: senderID,
+		Content:  req.Content,
+	}
+	if err := c.DB.Create(&message).Error; err != nil {
+		response.Fail(ctx, nil, "Failed to send message")
+		return
+	}
+	broadcastMessage(c.DB, message)
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+
+	// 客户端断开后不再写入，但仍然把回复生成完并保存，下次 GET /messages 可以看到
+	clientGone := ctx.Request.Context().Done()
+	send := func(event string, data interface{}) {
+		select {
+		case <-clientGone:
+			return
+		default:
+		}
+		ctx.SSEvent(event, data)
+		ctx.Writer.Flush()
+	}
+
+	send("message", message)
+
+	history, err := chatHistory(c.DB, chat.ID, receiver.ID, message.ID)
+	if err != nil {
+		log.Printf("Failed to load chat history: %v", err)
+	}
+
+	meta := usageMeta{UserID: senderID, PostID: post.ID, Purpose: model.UsageChatReply}
+	content, err := StreamAIReply(context.Background(), post.HeadImg, req.Content, history, meta, func(delta string) error {
+		send("delta", gin.H{"content": delta})
+		return nil
+	})
+	if err != nil {
+		log.Printf("AI stream failed: %v", err)
+		// 流式调用失败时交给任务队列重试，回复会稍后出现在消息列表中
+		if _, qerr := queue.Enqueue(c.DB, JobChatAIReply, ChatAIReplyJob{
+			ChatID:    chat.ID.String(),
+			PostID:    postID.String(),
+			MessageID: message.ID.String(),
+			AIUserID:  receiver.ID,
+			UserID:    senderID,
+			Content:   req.Content,
+		}); qerr != nil {
+			log.Printf("Failed to enqueue AI reply: %v", qerr)
+		}
+		send("error", gin.H{"error": "AI reply failed, it will be retried in the background"})
+		return
+	}
+
+	aiMessage := model.Message{
+		ChatID:   chat.ID,
+		SenderID: receiver.ID,
+		Content:  content,
+	}
+	if err := c.DB.Create(&aiMessage).Error; err != nil {
+		log.Printf("Failed to save AI message: %v", err)
+		send("error", gin.H{"error": "Failed to save AI reply"})
+		return
+	}
+	broadcastMessage(c.DB, aiMessage)
+
+	send("done", aiMessage)
 }
 
 func (c *ChatController) GetMessages(ctx *gin.Context) {
@@ -145,7 +284,7 @@ func (c *ChatController) GetMessages(ctx *gin.Context) {
 
 	// 获取该 Chat 下的所有 Message
 	var messages []model.Message
-	if err := c.DB.Where("chat_id = ?", chat.ID).Order("created_at DESC").Find(&messages).Error; err != nil {
+	if err := c.DB.Where("chat_id = ?", chat.ID).Order("created_at DESC, id DESC").Find(&messages).Error; err != nil {
 		response.Fail(ctx, nil, "Failed to retrieve messages")
 		return
 	}
@@ -166,6 +305,7 @@ func (c *ChatController) ChatList(ctx *gin.Context) {
 		OtherParticipantName string     `json:"other_participant_name"`
 		LastMessageContent   string     `json:"last_message_content"`
 		LastMessageTime      model.Time `json:"last_message_time"`
+		UnreadCount          int64      `json:"unread_count"`
 	}
 
 	if err := c.DB.Raw(`
@@ -177,7 +317,12 @@ func (c *ChatController) ChatList(ctx *gin.Context) {
 			IF(chats.sender_id = ?, chats.receiver_id, chats.sender_id) AS other_participant_id,
 			IF(chats.sender_id = ?, users_receiver.name, users_sender.name) AS other_participant_name,
 			latest_messages.content AS last_message_content,
-			latest_messages.created_at AS last_message_time
+			latest_messages.created_at AS last_message_time,
+			(
+				SELECT COUNT(*)
+				FROM messages
+				WHERE messages.chat_id = chats.id AND `+unreadCondition+`
+			) AS unread_count
 		FROM
 			chats
 		JOIN (
@@ -197,10 +342,101 @@ func (c *ChatController) ChatList(ctx *gin.Context) {
 			chats.sender_id = ? OR chats.receiver_id = ?
 		ORDER BY
 			latest_messages.created_at DESC
-	`, userID, userID, userID, userID).Scan(&conversations).Error; err != nil {
+	`, userID, userID, userID, userID, userID, userID, userID, userID).Scan(&conversations).Error; err != nil {
 		response.Fail(ctx, nil, "Failed to retrieve chat list")
 		return
 	}
 
 	response.Success(ctx, gin.H{"conversations": conversations}, "Conversations retrieved successfully")
 }
+
+// unreadCondition 筛选 chats 中对当前用户未读的 messages，需要依次绑定四次当前用户 ID
+//
+// 消息按 (created_at, id) 排序，和已读游标比较时两者一起比较，同一秒内的消息不会被漏掉或误算
+const unreadCondition = `messages.sender_id <> ? AND (
+	IF(chats.sender_id = ?, chats.sender_read_at, chats.receiver_read_at) IS NULL
+	OR (messages.created_at, messages.id) > (
+		IF(chats.sender_id = ?, chats.sender_read_at, chats.receiver_read_at),
+		IF(chats.sender_id = ?, chats.sender_read_message_id, chats.receiver_read_message_id)))`
+
+// MarkRead 把当前用户在 Chat 中的已读游标移动到指定消息，未指定时移动到最新消息
+func (c *ChatController) MarkRead(ctx *gin.Context) {
+	user, _ := ctx.Get("user")
+	userID := user.(model.User).ID
+
+	chat, ok := c.participantChat(ctx.Param("id"), userID)
+	if !ok {
+		response.Fail(ctx, nil, "Chat not found")
+		return
+	}
+
+	var req vo.MarkReadRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.Fail(ctx, nil, "Invalid request")
+		return
+	}
+
+	var message model.Message
+	query := c.DB.Where("chat_id = ?", chat.ID)
+	if req.MessageID != "" {
+		query = query.Where("id = ?", req.MessageID)
+	} else {
+		query = query.Order("created_at DESC, id DESC")
+	}
+	if err := query.First(&message).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(ctx, nil, "Message not found")
+		} else {
+			response.Fail(ctx, nil, "Failed to retrieve message")
+		}
+		return
+	}
+
+	// 游标只向前移动，避免旧的回执覆盖新的已读位置；比较放在 UPDATE 里，并发的回执也不会倒退
+	readAt := time.Time(message.CreatedAt)
+	atColumn, idColumn := chat.ReadCursorColumns(userID)
+	if err := c.DB.Model(&model.Chat{}).
+		Where("id = ?", chat.ID).
+		Where(fmt.Sprintf("%s IS NULL OR (%s, %s) < (?, ?)", atColumn, atColumn, idColumn), readAt, message.ID.String()).
+		Updates(map[string]interface{}{atColumn: readAt, idColumn: message.ID.String()}).Error; err != nil {
+		response.Fail(ctx, nil, "Failed to mark chat as read")
+		return
+	}
+
+	realtime.DefaultHub.SendToUsers(realtime.Event{
+		Type:      realtime.EventRead,
+		ChatID:    chat.ID.String(),
+		MessageID: message.ID.String(),
+		UserID:    userID,
+	}, otherParticipant(chat, userID))
+
+	var unreadCount int64
+	c.DB.Raw(`
+		SELECT COUNT(*)
+		FROM messages
+		JOIN chats ON chats.id = messages.chat_id
+		WHERE chats.id = ? AND `+unreadCondition,
+		chat.ID, userID, userID, userID, userID).Scan(&unreadCount)
+
+	response.Success(ctx, gin.H{"unread_count": unreadCount}, "Chat marked as read")
+}
+
+// UnreadCount 返回当前用户所有会话的未读消息总数，用于全局角标
+func (c *ChatController) UnreadCount(ctx *gin.Context) {
+	user, _ := ctx.Get("user")
+	userID := user.(model.User).ID
+
+	var unreadCount int64
+	if err := c.DB.Raw(`
+		SELECT COUNT(*)
+		FROM messages
+		JOIN chats ON chats.id = messages.chat_id
+		JOIN posts ON chats.post_id = posts.id
+		WHERE (chats.sender_id = ? OR chats.receiver_id = ?) AND chats.deleted_at IS NULL AND `+unreadCondition,
+		userID, userID, userID, userID, userID, userID).Scan(&unreadCount).Error; err != nil {
+		response.Fail(ctx, nil, "Failed to count unread messages")
+		return
+	}
+
+	response.Success(ctx, gin.H{"unread_count": unreadCount}, "Unread count retrieved successfully")
+}
@@ -31,6 +31,11 @@ func (p PostController) AddComment(ctx *gin.Context) {
 		return
 	}
 
+	if _, err := findVisiblePost(p.DB, currentUser(ctx), postIdStr); err != nil {
+		response.Fail(ctx, nil, "Post does not exist")
+		return
+	}
+
 	// 定义接收数据的结构体
 	var commentVo vo.CreateCommentRequest
 	// 绑定数据
@@ -58,6 +63,11 @@ func (p PostController) AddComment(ctx *gin.Context) {
 func (p PostController) GetComments(ctx *gin.Context) {
 	postId := ctx.Params.ByName("id")
 
+	if _, err := findVisiblePost(p.DB, currentUser(ctx), postId); err != nil {
+		response.Fail(ctx, nil, "Post does not exist")
+		return
+	}
+
 	var comments []model.Comment
 	// 预加载User关联，以获取每条评论的用户信息
 	if err := p.DB.Where("post_id = ?", postId).Preload("User").Find(&comments).Error; err != nil {
@@ -0,0 +1,278 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/model"
+	"owlllovo/ginessential/response"
+	"owlllovo/ginessential/vo"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ICritiqueController interface {
+	List(ctx *gin.Context)
+	Regenerate(ctx *gin.Context)
+	Rate(ctx *gin.Context)
+	Unrate(ctx *gin.Context)
+	RatingReport(ctx *gin.Context)
+}
+
+// CritiqueController 管理帖子 AI 点评的版本和评价
+type CritiqueController struct {
+	DB *gorm.DB
+}
+
+func NewCritiqueController() ICritiqueController {
+	db := common.GetDB()
+	db.AutoMigrate(&model.PostCritique{}, &model.CritiqueRating{})
+	return CritiqueController{DB: db}
+}
+
+// critiqueWithRatings 是点评及其评价统计，MyRating 是当前用户的评价
+type critiqueWithRatings struct {
+	model.PostCritique
+	Up       int64 `json:"up"`
+	Down     int64 `json:"down"`
+	MyRating int   `json:"my_rating"`
+}
+
+// requireVisiblePost 是 findVisiblePost 的包装，帖子不可见时直接写失败响应
+func (c CritiqueController) requireVisiblePost(ctx *gin.Context, postId string) (*model.Post, bool) {
+	post, err := findVisiblePost(c.DB, currentUser(ctx), postId)
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Post does not exist"}, "")
+		return nil, false
+	}
+	return post, true
+}
+
+// List 返回帖子所有版本的 AI 点评，最新的在前
+func (c CritiqueController) List(ctx *gin.Context) {
+	post, ok := c.requireVisiblePost(ctx, ctx.Param("id"))
+	if !ok {
+		return
+	}
+
+	var critiques []model.PostCritique
+	if err := c.DB.Where("post_id = ?", post.ID).Order("version DESC").Find(&critiques).Error; err != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to retrieve critiques"}, "")
+		return
+	}
+
+	ids := make([]uint, 0, len(critiques))
+	for _, critique := range critiques {
+		ids = append(ids, critique.ID)
+	}
+	var ratings []model.CritiqueRating
+	if len(ids) > 0 {
+		c.DB.Where("critique_id IN ?", ids).Find(&ratings)
+	}
+
+	user := currentUser(ctx)
+	result := make([]critiqueWithRatings, 0, len(critiques))
+	for _, critique := range critiques {
+		item := critiqueWithRatings{PostCritique: critique}
+		for _, rating := range ratings {
+			if rating.CritiqueID != critique.ID {
+				continue
+			}
+			if rating.Rating == model.RatingUp {
+				item.Up++
+			} else {
+				item.Down++
+			}
+			if user != nil && rating.UserID == user.ID {
+				item.MyRating = rating.Rating
+			}
+		}
+		result = append(result, item)
+	}
+
+	response.Success(ctx, gin.H{"critiques": result, "dimensions": critiqueDimensions}, "")
+}
+
+var (
+	errCritiqueInFlight     = errors.New("a critique is already being generated for this post")
+	errCritiqueNotAvailable = errors.New("critiques are only available for pending and approved posts")
+)
+
+// critiqueAllowed 草稿还没有点评，已拒绝和已归档的帖子不再生成新的点评
+func critiqueAllowed(status string) bool {
+	return status == model.PostPending || status == model.PostApproved
+}
+
+// Regenerate 由作者或审核人员要求重新生成 AI 点评，旧版本保留；同一帖子同时只能有一个生成任务
+func (c CritiqueController) Regenerate(ctx *gin.Context) {
+	user, _ := ctx.Get("user")
+	requester := user.(model.User)
+	post, err := findVisiblePost(c.DB.Preload("Category"), &requester, ctx.Param("id"))
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Post does not exist"}, "")
+		return
+	}
+	if requester.ID != post.UserId && !common.HasPermission(requester.Role, model.PermPostApprove) {
+		response.Fail(ctx, gin.H{"error": "Only author and moderators can regenerate critiques"}, "")
+		return
+	}
+	if !critiqueAllowed(post.Status) {
+		response.Fail(ctx, gin.H{"error": errCritiqueNotAvailable.Error()}, "")
+		return
+	}
+
+	if err := checkAIQuota(c.DB, requester); err != nil {
+		if quotaErr, ok := isQuotaError(err); ok {
+			response.Fail(ctx, gin.H{"error": quotaErr.Error()}, "AI quota exceeded")
+			return
+		}
+		response.Fail(ctx, gin.H{"error": "Failed to check AI quota"}, "")
+		return
+	}
+
+	var category model.Category
+	if post.Category != nil {
+		category = *post.Category
+	}
+	var job *model.Job
+	err = c.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁住帖子行，同一帖子的请求在检查进行中的任务和入队之间不会交错
+		var locked model.Post
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", post.ID).First(&locked).Error; err != nil {
+			return err
+		}
+		if !critiqueAllowed(locked.Status) {
+			return errCritiqueNotAvailable
+		}
+		if critiqueInFlight(tx, locked) {
+			return errCritiqueInFlight
+		}
+		var err error
+		job, err = enqueueCritique(tx, *post, category, requester.ID)
+		return err
+	})
+	if errors.Is(err, errCritiqueInFlight) || errors.Is(err, errCritiqueNotAvailable) {
+		response.Fail(ctx, gin.H{"error": err.Error()}, "")
+		return
+	} else if err != nil {
+		log.Printf("Failed to enqueue critique regeneration: %v", err)
+		response.Fail(ctx, gin.H{"error": "Failed to request a new critique"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"job_id": job.ID}, "Critique regeneration requested")
+}
+
+func (c CritiqueController) findCritique(ctx *gin.Context) (*model.PostCritique, bool) {
+	critiqueId, _ := strconv.Atoi(ctx.Param("id"))
+	var critique model.PostCritique
+	if err := c.DB.First(&critique, critiqueId).Error; err != nil {
+		response.Fail(ctx, gin.H{"error": "Critique does not exist"}, "")
+		return nil, false
+	}
+	if _, ok := c.requireVisiblePost(ctx, critique.PostID.String()); !ok {
+		return nil, false
+	}
+	return &critique, true
+}
+
+// Rate 点赞或点踩一条 AI 点评，重复评价会覆盖之前的评价
+func (c CritiqueController) Rate(ctx *gin.Context) {
+	var request vo.CritiqueRatingRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		response.Fail(ctx, gin.H{"error": "Data Error, rating must be up or down"}, "")
+		return
+	}
+	critique, ok := c.findCritique(ctx)
+	if !ok {
+		return
+	}
+
+	user, _ := ctx.Get("user")
+	rating := model.CritiqueRating{
+		CritiqueID: critique.ID,
+		UserID:     user.(model.User).ID,
+		Rating:     model.RatingUp,
+		Reason:     request.Reason,
+	}
+	if request.Rating == "down" {
+		rating.Rating = model.RatingDown
+	}
+	if err := c.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "critique_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rating", "reason", "updated_at"}),
+	}).Create(&rating).Error; err != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to save rating"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"rating": rating}, "Rating saved")
+}
+
+func (c CritiqueController) Unrate(ctx *gin.Context) {
+	critique, ok := c.findCritique(ctx)
+	if !ok {
+		return
+	}
+
+	user, _ := ctx.Get("user")
+	if err := c.DB.Where("critique_id = ? AND user_id = ?", critique.ID, user.(model.User).ID).
+		Delete(&model.CritiqueRating{}).Error; err != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to delete rating"}, "")
+		return
+	}
+
+	response.Success(ctx, nil, "")
+}
+
+// ratingGroups 是评价报表可以使用的分组方式
+var ratingGroups = map[string][]string{
+	"template": {"post_critiques.prompt_template_id", "post_critiques.prompt_version"},
+	"provider": {"post_critiques.provider", "post_critiques.model"},
+}
+
+// ratingRow 是评价报表中的一行
+type ratingRow struct {
+	PromptTemplateID *uint   `json:"prompt_template_id,omitempty"`
+	PromptVersion    *int    `json:"prompt_version,omitempty"`
+	Provider         *string `json:"provider,omitempty"`
+	Model            *string `json:"model,omitempty"`
+	Critiques        int64   `json:"critiques"`
+	Up               int64   `json:"up"`
+	Down             int64   `json:"down"`
+	Approval         float64 `json:"approval"`
+}
+
+// RatingReport 按 ?group=template|provider 汇总点评的评价，用于比较提示词模板和 provider 的质量
+func (c CritiqueController) RatingReport(ctx *gin.Context) {
+	group := ctx.DefaultQuery("group", "template")
+	columns, ok := ratingGroups[group]
+	if !ok {
+		response.Fail(ctx, gin.H{"error": "group must be template or provider"}, "")
+		return
+	}
+
+	var rows []ratingRow
+	err := c.DB.Table("post_critiques").
+		Select(columns[0] + ", " + columns[1] + ", COUNT(DISTINCT post_critiques.id) AS critiques, " +
+			"COALESCE(SUM(CASE WHEN critique_ratings.rating > 0 THEN 1 ELSE 0 END), 0) AS up, " +
+			"COALESCE(SUM(CASE WHEN critique_ratings.rating < 0 THEN 1 ELSE 0 END), 0) AS down").
+		Joins("LEFT JOIN critique_ratings ON critique_ratings.critique_id = post_critiques.id").
+		Group(columns[0] + ", " + columns[1]).
+		Order(columns[0] + ", " + columns[1]).
+		Scan(&rows).Error
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to build rating report"}, "")
+		return
+	}
+	for i := range rows {
+		if total := rows[i].Up + rows[i].Down; total > 0 {
+			rows[i].Approval = float64(rows[i].Up) / float64(total)
+		}
+	}
+
+	response.Success(ctx, gin.H{"group": group, "rows": rows}, "")
+}
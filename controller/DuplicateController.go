@@ -0,0 +1,307 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/imaging"
+	"owlllovo/ginessential/model"
+	"owlllovo/ginessential/response"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+type IDuplicateController interface {
+	Clusters(ctx *gin.Context)
+}
+
+// DuplicateController 按感知哈希查找重复或近似的画作
+type DuplicateController struct {
+	DB *gorm.DB
+}
+
+func NewDuplicateController() IDuplicateController {
+	db := common.GetDB()
+	db.AutoMigrate(&model.Asset{})
+	backfillPHashBands(db)
+	return DuplicateController{DB: db}
+}
+
+// backfillPHashBands 为加入分段列之前的上传填充分段
+func backfillPHashBands(db *gorm.DB) {
+	result := db.Model(&model.Asset{}).
+		Where("phash <> 0 AND phash0 = 0 AND phash1 = 0 AND phash2 = 0 AND phash3 = 0").
+		UpdateColumns(map[string]interface{}{
+			"phash0": gorm.Expr("phash & 65535"),
+			"phash1": gorm.Expr("(phash >> 16) & 65535"),
+			"phash2": gorm.Expr("(phash >> 32) & 65535"),
+			"phash3": gorm.Expr("(phash >> 48) & 65535"),
+		})
+	if result.Error != nil {
+		log.Printf("Failed to backfill phash bands: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Backfilled phash bands for %d uploads", result.RowsAffected)
+	}
+}
+
+// maxBandRadius 是按分段筛选时每段允许的最大距离，更大的阈值候选太多，退回逐个比较
+const maxBandRadius = 3
+
+// bandRadius 返回阈值对应的每段距离，不适合按分段筛选时返回 false
+func bandRadius(threshold int) (int, bool) {
+	radius := threshold / imaging.PHashBands
+	return radius, radius <= maxBandRadius
+}
+
+// duplicateThreshold 返回判定为近似图片的最大汉明距离，可以用 ?threshold= 覆盖配置
+func duplicateThreshold(ctx *gin.Context) int {
+	if ctx != nil {
+		if threshold, err := strconv.Atoi(ctx.Query("threshold")); err == nil && threshold >= 0 && threshold <= 32 {
+			return threshold
+		}
+	}
+	if threshold := viper.GetInt("duplicates.threshold"); threshold > 0 {
+		return threshold
+	}
+	return 10
+}
+
+// duplicateMatch 是与某次上传相似的另一个上传
+type duplicateMatch struct {
+	model.Asset
+	Distance int `json:"distance"`
+}
+
+// similarAssets 返回与 asset 的感知哈希距离不超过 threshold 的其它上传，距离近的在前
+func similarAssets(db *gorm.DB, asset model.Asset, threshold, limit int) ([]duplicateMatch, error) {
+	var matches []duplicateMatch
+	if asset.PHash == 0 {
+		return matches, nil
+	}
+	query := db.Model(&model.Asset{}).
+		Select("*, BIT_COUNT(phash ^ ?) AS distance", asset.PHash).
+		Where("id <> ? AND phash <> 0", asset.ID).
+		Where("BIT_COUNT(phash ^ ?) <= ?", asset.PHash, threshold)
+	// BIT_COUNT 用不上索引，先用分段索引筛出至少有一段足够接近的候选
+	if radius, ok := bandRadius(threshold); ok {
+		bands := imaging.Bands(asset.PHash)
+		candidates := db.Session(&gorm.Session{NewDB: true})
+		for i, band := range bands {
+			candidates = candidates.Or(fmt.Sprintf("phash%d IN ?", i), imaging.BandNeighbors(band, radius))
+		}
+		query = query.Where(candidates)
+	}
+	err := query.Order("distance ASC, id ASC").Limit(limit).Find(&matches).Error
+	for i := range matches {
+		matches[i].Images = imaging.URLs(matches[i].StorageKey)
+	}
+	return matches, err
+}
+
+// duplicateWarning 生成上传接口的重复提示：自己以前上传过的相似图片会列出来，
+// 别人的图片只返回数量，不暴露其他孩子的作品；没有相似图片时返回 nil
+func duplicateWarning(db *gorm.DB, asset model.Asset) gin.H {
+	matches, err := similarAssets(db, asset, duplicateThreshold(nil), 20)
+	if err != nil || len(matches) == 0 {
+		return nil
+	}
+	own := make([]gin.H, 0)
+	others := 0
+	for _, match := range matches {
+		if match.UserID != asset.UserID {
+			others++
+			continue
+		}
+		own = append(own, gin.H{
+			"asset_id":   match.ID,
+			"distance":   match.Distance,
+			"images":     match.Images,
+			"created_at": match.CreatedAt,
+		})
+	}
+	message := "You have uploaded a similar drawing before"
+	if others > 0 {
+		message = "A similar drawing has already been uploaded by another user"
+	}
+	return gin.H{"message": message, "own": own, "others": others}
+}
+
+// clusterAsset 是重复分组中的一个上传，附带上传者和使用它的帖子
+type clusterAsset struct {
+	ID        uint              `json:"id"`
+	User      gin.H             `json:"user"`
+	Images    map[string]string `json:"images"`
+	Posts     []gin.H           `json:"posts"`
+	CreatedAt model.Time        `json:"created_at"`
+}
+
+type duplicateCluster struct {
+	Assets      []clusterAsset `json:"assets"`
+	Users       int            `json:"users"`
+	MinDistance int            `json:"min_distance"`
+}
+
+// Clusters 列出疑似重复的上传分组，距离不超过 threshold 的上传归为一组。
+// ?cross_user=false 时同一用户的重复上传也会列出；?days= 只检查最近几天的上传。
+// 阈值不超过 15 时只比较至少有一段足够接近的上传，否则两两比较；最多检查 duplicates.maxScan 个最近的上传
+func (d DuplicateController) Clusters(ctx *gin.Context) {
+	threshold := duplicateThreshold(ctx)
+	crossUser := ctx.DefaultQuery("cross_user", "true") != "false"
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	maxScan := viper.GetInt("duplicates.maxScan")
+	if maxScan <= 0 {
+		maxScan = 5000
+	}
+
+	query := d.DB.Model(&model.Asset{}).Where("phash <> 0")
+	if days, err := strconv.Atoi(ctx.Query("days")); err == nil && days > 0 {
+		query = query.Where("created_at >= ?", time.Now().AddDate(0, 0, -days))
+	}
+	var assets []model.Asset
+	if err := query.Order("id DESC").Limit(maxScan).Find(&assets).Error; err != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to load uploads"}, "")
+		return
+	}
+
+	// 并查集：距离足够近的两个上传合并到同一组
+	parent := make([]int, len(assets))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	minDistance := make(map[int]int)
+	compare := func(i, j int) {
+		if crossUser && assets[i].UserID == assets[j].UserID {
+			return
+		}
+		distance := imaging.Distance(assets[i].PHash, assets[j].PHash)
+		if distance > threshold {
+			return
+		}
+		ri, rj := find(i), find(j)
+		best := distance
+		for _, r := range []int{ri, rj} {
+			if m, ok := minDistance[r]; ok && m < best {
+				best = m
+			}
+		}
+		parent[rj] = ri
+		minDistance[ri] = best
+	}
+
+	if radius, ok := bandRadius(threshold); ok {
+		// 按每一段分桶，只比较至少有一段距离不超过 radius 的上传
+		var buckets [imaging.PHashBands]map[uint16][]int
+		for b := range buckets {
+			buckets[b] = make(map[uint16][]int)
+		}
+		for i := range assets {
+			for b, band := range imaging.Bands(assets[i].PHash) {
+				buckets[b][band] = append(buckets[b][band], i)
+			}
+		}
+		for i := range assets {
+			for b, band := range imaging.Bands(assets[i].PHash) {
+				for _, value := range imaging.BandNeighbors(band, radius) {
+					for _, j := range buckets[b][value] {
+						if j > i {
+							compare(i, j)
+						}
+					}
+				}
+			}
+		}
+	} else {
+		for i := range assets {
+			for j := i + 1; j < len(assets); j++ {
+				compare(i, j)
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	for i := range assets {
+		root := find(i)
+		groups[root] = append(groups[root], i)
+	}
+	roots := make([]int, 0, len(groups))
+	for root, members := range groups {
+		if len(members) > 1 {
+			roots = append(roots, root)
+		}
+	}
+	sort.Slice(roots, func(a, b int) bool {
+		if len(groups[roots[a]]) != len(groups[roots[b]]) {
+			return len(groups[roots[a]]) > len(groups[roots[b]])
+		}
+		return minDistance[roots[a]] < minDistance[roots[b]]
+	})
+	total := len(roots)
+	if len(roots) > limit {
+		roots = roots[:limit]
+	}
+
+	// 只为返回的分组查询用户和帖子
+	var assetIDs, userIDs []uint
+	for _, root := range roots {
+		for _, i := range groups[root] {
+			assetIDs = append(assetIDs, assets[i].ID)
+			userIDs = append(userIDs, assets[i].UserID)
+		}
+	}
+	users := make(map[uint]model.User)
+	posts := make(map[uint][]gin.H)
+	if len(assetIDs) > 0 {
+		var userList []model.User
+		d.DB.Where("id IN ?", userIDs).Find(&userList)
+		for _, user := range userList {
+			users[user.ID] = user
+		}
+		var postList []model.Post
+		d.DB.Select("id", "asset_id", "title", "status", "user_id").Where("asset_id IN ?", assetIDs).Find(&postList)
+		for _, post := range postList {
+			posts[*post.AssetID] = append(posts[*post.AssetID], gin.H{"id": post.ID, "title": post.Title, "status": post.Status})
+		}
+	}
+
+	clusters := make([]duplicateCluster, 0, len(roots))
+	for _, root := range roots {
+		cluster := duplicateCluster{MinDistance: minDistance[root]}
+		seenUsers := make(map[uint]bool)
+		for _, i := range groups[root] {
+			asset := assets[i]
+			seenUsers[asset.UserID] = true
+			cluster.Assets = append(cluster.Assets, clusterAsset{
+				ID:        asset.ID,
+				User:      gin.H{"id": asset.UserID, "name": users[asset.UserID].Name},
+				Images:    asset.Images,
+				Posts:     posts[asset.ID],
+				CreatedAt: asset.CreatedAt,
+			})
+		}
+		cluster.Users = len(seenUsers)
+		clusters = append(clusters, cluster)
+	}
+
+	response.Success(ctx, gin.H{
+		"data":      clusters,
+		"total":     total,
+		"threshold": threshold,
+		"scanned":   len(assets),
+		"truncated": len(assets) == maxScan,
+	}, "Success")
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"owlllovo/ginessential/storage"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeImage 从存储后端读取图片返回给浏览器，文件名带 uuid，内容不会变，可以长期缓存
+func ServeImage(ctx *gin.Context) {
+	key := strings.TrimPrefix(ctx.Param("key"), "/")
+	store := storage.Default()
+
+	info, err := store.Stat(ctx.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) || errors.Is(err, storage.ErrInvalidKey) {
+			ctx.Status(http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to stat image %s: %v", key, err)
+		ctx.Status(http.StatusBadGateway)
+		return
+	}
+
+	header := ctx.Writer.Header()
+	header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	if info.ETag != "" {
+		header.Set("ETag", info.ETag)
+		if ctx.GetHeader("If-None-Match") == info.ETag {
+			ctx.Status(http.StatusNotModified)
+			return
+		}
+	}
+	if !info.LastModified.IsZero() {
+		header.Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header.Set("Content-Type", contentType)
+	if info.Size > 0 {
+		header.Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	if ctx.Request.Method == http.MethodHead {
+		ctx.Status(http.StatusOK)
+		return
+	}
+
+	body, err := store.Get(ctx.Request.Context(), key)
+	if err != nil {
+		log.Printf("Failed to read image %s: %v", key, err)
+		ctx.Status(http.StatusBadGateway)
+		return
+	}
+	defer body.Close()
+	ctx.Status(http.StatusOK)
+	if _, err := io.Copy(ctx.Writer, body); err != nil {
+		log.Printf("Failed to send image %s: %v", key, err)
+	}
+}
@@ -31,10 +31,9 @@ func (p PostController) LikePost(ctx *gin.Context) {
 	userID := user.(model.User).ID
 	postID := ctx.Param("id")
 	log.Println(userID)
-	var post model.Post
-
 	// 检查帖子是否存在
-	if err := p.DB.Where("id = ?", postID).First(&post).Error; err != nil {
+	post, err := findVisiblePost(p.DB, currentUser(ctx), postID)
+	if err != nil {
 		response.Fail(ctx, nil, "帖子不存在")
 		return
 	}
@@ -106,8 +105,11 @@ func (p PostController) LikeRank(ctx *gin.Context) {
 
 	var postsWithLikeCount []PostWithLikeCount
 
+	visible := visiblePosts(currentUser(ctx))
+
 	// 查询帖子以及对应的点赞数量
 	p.DB.Model(&model.Post{}).
+		Scopes(visible).
 		Preload("Category").
 		Preload("User").
 		Select("posts.*, COUNT(likes.id) as like_count").
@@ -126,7 +128,7 @@ func (p PostController) LikeRank(ctx *gin.Context) {
 
 	// 查询总帖子数，用于分页
 	var total int64
-	p.DB.Model(&model.Post{}).Count(&total)
+	p.DB.Model(&model.Post{}).Scopes(visible).Count(&total)
 
 	response.Success(ctx, gin.H{"data": postsWithLikeCount, "total": total}, "Success")
 }
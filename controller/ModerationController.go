@@ -0,0 +1,258 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/model"
+	"owlllovo/ginessential/response"
+	"owlllovo/ginessential/vo"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+var (
+	errPostStatusChanged = errors.New("post status was changed by someone else")
+	errPostClaimed       = errors.New("post is claimed by another moderator")
+	errSelfApprove       = errors.New("authors cannot approve their own posts")
+	errReasonRequired    = errors.New("rejection reason is required")
+)
+
+// transitionError 表示当前状态不允许执行该转换
+type transitionError struct {
+	from, to string
+}
+
+func (e transitionError) Error() string {
+	return "cannot change post from " + e.from + " to " + e.to
+}
+
+// claimTTL 返回审核领取的有效期，过期后其他审核人员可以接手
+func claimTTL() time.Duration {
+	if ttl := viper.GetDuration("moderation.claimTTL"); ttl > 0 {
+		return ttl
+	}
+	return 15 * time.Minute
+}
+
+// claimedByOther 判断帖子是否被其他审核人员领取且尚未过期
+func claimedByOther(post model.Post, userID uint) bool {
+	return post.ClaimedBy != 0 && post.ClaimedBy != userID &&
+		post.ClaimedAt != nil && time.Since(*post.ClaimedAt) < claimTTL()
+}
+
+// postActor 返回用户对帖子的身份；作者即使拥有审核权限，对自己的帖子也只算作者
+func postActor(user model.User, post model.Post) string {
+	if user.ID == post.UserId {
+		return model.ActorAuthor
+	}
+	if common.HasPermission(user.Role, model.PermPostApprove) {
+		return model.ActorModerator
+	}
+	return ""
+}
+
+// transitionPost 在事务中修改帖子状态并写入审核历史，状态已被别人修改时返回 errPostStatusChanged
+func transitionPost(db *gorm.DB, post *model.Post, to string, actorID uint, reason, note string) error {
+	return transitionPostWhere(db, post, to, actorID, reason, note, nil)
+}
+
+// transitionPostWhere 与 transitionPost 相同，但帖子还必须满足 Where(query, args...)，不满足时同样返回 errPostStatusChanged
+func transitionPostWhere(db *gorm.DB, post *model.Post, to string, actorID uint, reason, note string, query interface{}, args ...interface{}) error {
+	from := post.Status
+	now := time.Now()
+	updates := map[string]interface{}{"status": to, "claimed_by": 0, "claimed_at": nil}
+	if to == model.PostRejected {
+		updates["rejection_reason"] = reason
+	}
+	if to == model.PostPending {
+		updates["submitted_at"] = now
+		updates["screening_verdict"] = ""
+	}
+	if note != "" {
+		updates["moderator_note"] = note
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		update := tx.Model(&model.Post{}).Where("id = ? AND status = ?", post.ID, from)
+		if query != nil {
+			update = update.Where(query, args...)
+		}
+		result := update.Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errPostStatusChanged
+		}
+		if err := tx.Create(&model.PostModeration{
+			PostID:     post.ID,
+			FromStatus: from,
+			ToStatus:   to,
+			ActorID:    actorID,
+			Reason:     reason,
+			Note:       note,
+		}).Error; err != nil {
+			return err
+		}
+		// 每次重新进入待审核都要重新预审；草稿第一次提交时才生成 AI 点评
+		if to == model.PostPending {
+			if err := enqueueScreening(tx, *post); err != nil {
+				return err
+			}
+			if from == model.PostDraft {
+				return enqueueFirstCritique(tx, *post)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	post.Status = to
+	post.ClaimedBy = 0
+	post.ClaimedAt = nil
+	if to == model.PostRejected {
+		post.RejectionReason = reason
+	}
+	if to == model.PostPending {
+		post.SubmittedAt = &now
+		post.ScreeningVerdict = ""
+	}
+	if note != "" {
+		post.ModeratorNote = note
+	}
+	return nil
+}
+
+// moderatePost 校验权限、状态机和领取状态后修改帖子状态
+func moderatePost(db *gorm.DB, user model.User, postId string, to string, request vo.ModerationRequest) (*model.Post, error) {
+	if to == model.PostRejected && request.Reason == "" {
+		return nil, errReasonRequired
+	}
+
+	var post model.Post
+	if err := db.Where("id = ?", postId).First(&post).Error; err != nil {
+		return nil, err
+	}
+
+	actor := postActor(user, post)
+	if !model.CanTransitionPost(post.Status, to, actor) {
+		if actor == model.ActorAuthor && to == model.PostApproved {
+			return nil, errSelfApprove
+		}
+		return nil, transitionError{from: post.Status, to: to}
+	}
+	if actor != model.ActorModerator {
+		if err := transitionPost(db, &post, to, user.ID, request.Reason, request.Note); err != nil {
+			return nil, err
+		}
+		return &post, nil
+	}
+
+	// 领取检查放在 UPDATE 条件里，避免读到帖子之后别人刚好领取
+	err := transitionPostWhere(db, &post, to, user.ID, request.Reason, request.Note,
+		"claimed_by = 0 OR claimed_by IS NULL OR claimed_by = ? OR claimed_at IS NULL OR claimed_at < ?",
+		user.ID, time.Now().Add(-claimTTL()))
+	if errors.Is(err, errPostStatusChanged) {
+		// 区分是被别人领取了还是状态已经变了
+		var current model.Post
+		if db.Where("id = ?", post.ID).First(&current).Error == nil && current.Status == post.Status && claimedByOther(current, user.ID) {
+			return nil, errPostClaimed
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// changeStatus 是 approve / reject / submit / archive 等接口的共同实现
+func (p PostController) changeStatus(ctx *gin.Context, to string, msg string) {
+	var request vo.ModerationRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		response.Fail(ctx, gin.H{"error": "Data Error"}, "")
+		return
+	}
+
+	user, _ := ctx.Get("user")
+	post, err := moderatePost(p.DB, user.(model.User), ctx.Param("id"), to, request)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(ctx, gin.H{"error": "Post not found"}, "Post does not exist")
+			return
+		}
+		var terr transitionError
+		if errors.As(err, &terr) || errors.Is(err, errPostStatusChanged) || errors.Is(err, errPostClaimed) ||
+			errors.Is(err, errSelfApprove) || errors.Is(err, errReasonRequired) {
+			response.Fail(ctx, gin.H{"error": err.Error()}, "")
+			return
+		}
+		response.Fail(ctx, gin.H{"error": err.Error()}, "Failed to update post status")
+		return
+	}
+
+	data := gin.H{"post": post}
+	if common.HasPermission(user.(model.User).Role, model.PermPostApprove) {
+		data["moderator_note"] = post.ModeratorNote
+	}
+	response.Success(ctx, data, msg)
+}
+
+// 在 PostController 中添加 ApprovePost 方法
+func (p PostController) ApprovePost(ctx *gin.Context) {
+	p.changeStatus(ctx, model.PostApproved, "Post approved successfully")
+}
+
+func (p PostController) RejectPost(ctx *gin.Context) {
+	p.changeStatus(ctx, model.PostRejected, "Post rejected successfully")
+}
+
+// SubmitPost 作者把草稿、被驳回或已归档的帖子提交审核
+func (p PostController) SubmitPost(ctx *gin.Context) {
+	p.changeStatus(ctx, model.PostPending, "Post submitted for review")
+}
+
+func (p PostController) WithdrawPost(ctx *gin.Context) {
+	p.changeStatus(ctx, model.PostDraft, "Post moved back to draft")
+}
+
+func (p PostController) ArchivePost(ctx *gin.Context) {
+	p.changeStatus(ctx, model.PostArchived, "Post archived successfully")
+}
+
+// ModerationHistory 返回帖子的审核记录，只有作者和审核人员可以查看
+func (p PostController) ModerationHistory(ctx *gin.Context) {
+	var post model.Post
+	if err := p.DB.Where("id = ?", ctx.Param("id")).First(&post).Error; err != nil {
+		response.Fail(ctx, gin.H{"error": "Post does not exist"}, "")
+		return
+	}
+
+	user, _ := ctx.Get("user")
+	if postActor(user.(model.User), post) == "" {
+		response.Fail(ctx, gin.H{"error": "Post does not belong to you, access denied"}, "")
+		return
+	}
+
+	var history []model.PostModeration
+	if err := p.DB.Preload("Actor", func(db *gorm.DB) *gorm.DB {
+		// 只返回操作人的 id 和名字，不暴露手机号等信息
+		return db.Select("id", "name")
+	}).Where("post_id = ?", post.ID).Order("id").Find(&history).Error; err != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to retrieve moderation history"}, "")
+		return
+	}
+	// 审核备注是内部信息，作者只能看到状态变化和驳回原因
+	if !common.HasPermission(user.(model.User).Role, model.PermPostApprove) {
+		for i := range history {
+			history[i].Note = ""
+		}
+	}
+
+	response.Success(ctx, gin.H{"history": history}, "Moderation history retrieved successfully")
+}
@@ -0,0 +1,309 @@
+package controller
+
+import (
+	"errors"
+	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/imaging"
+	"owlllovo/ginessential/model"
+	"owlllovo/ginessential/response"
+	"owlllovo/ginessential/vo"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+type IModerationQueueController interface {
+	List(ctx *gin.Context)
+	Stats(ctx *gin.Context)
+	Claim(ctx *gin.Context)
+	Release(ctx *gin.Context)
+	BulkApprove(ctx *gin.Context)
+	BulkReject(ctx *gin.Context)
+}
+
+// ModerationQueueController 是管理员的待审核队列
+type ModerationQueueController struct {
+	DB *gorm.DB
+}
+
+func NewModerationQueueController() IModerationQueueController {
+	db := common.GetDB()
+	db.AutoMigrate(&model.Post{}, &model.PostModeration{}, &model.PostScreening{})
+	return ModerationQueueController{DB: db}
+}
+
+// queueItem 是队列中的一项，附带作者、分类、缩略图、AI 点评和 AI 预审结果
+type queueItem struct {
+	ID           string               `json:"id"`
+	Title        string               `json:"title"`
+	Author       gin.H                `json:"author"`
+	Category     gin.H                `json:"category"`
+	Thumbnail    string               `json:"thumbnail"`
+	AICritique   string               `json:"ai_critique"`
+	Screening    *model.PostScreening `json:"screening"`
+	SubmittedAt  *time.Time           `json:"submitted_at"`
+	WaitingHours float64              `json:"waiting_hours"`
+	ClaimedBy    uint                 `json:"claimed_by"`
+	ClaimedAt    *time.Time           `json:"claimed_at"`
+	// ModeratorNote 是最近一次的审核备注，只在审核队列中返回
+	ModeratorNote string `json:"moderator_note"`
+}
+
+// slaHours 返回超时阈值，可以用 ?hours= 覆盖配置
+func slaHours(ctx *gin.Context) int {
+	if hours, err := strconv.Atoi(ctx.Query("hours")); err == nil && hours > 0 {
+		return hours
+	}
+	if hours := viper.GetInt("moderation.slaHours"); hours > 0 {
+		return hours
+	}
+	return 24
+}
+
+// pendingQueue 是待审核帖子的查询条件
+func pendingQueue(db *gorm.DB) *gorm.DB {
+	return db.Model(&model.Post{}).Where("status = ?", model.PostPending)
+}
+
+// queueOrder 按提交时间排序，提交时间为空时退回创建时间
+const queueOrder = "COALESCE(submitted_at, created_at) ASC"
+
+// List 按提交时间从早到晚列出待审核的帖子，?unclaimed=true 只返回无人领取的，
+// ?flagged=true 只返回被 AI 预审标记的
+func (q ModerationQueueController) List(ctx *gin.Context) {
+	pageNum, _ := strconv.Atoi(ctx.DefaultQuery("pageNum", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+	if pageNum < 1 {
+		pageNum = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := pendingQueue(q.DB)
+	if ctx.Query("unclaimed") == "true" {
+		query = query.Where("claimed_by = 0 OR claimed_at IS NULL OR claimed_at < ?", time.Now().Add(-claimTTL()))
+	}
+
+	if ctx.Query("flagged") == "true" {
+		query = query.Where("screening_verdict IN ?", []string{model.ScreeningReview, model.ScreeningUnsafe})
+	}
+
+	var total int64
+	query.Session(&gorm.Session{}).Count(&total)
+
+	var posts []model.Post
+	if err := query.Preload("Category").Preload("User").Order(queueOrder).
+		Offset((pageNum - 1) * pageSize).Limit(pageSize).Find(&posts).Error; err != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to retrieve moderation queue"}, "")
+		return
+	}
+
+	critiques := q.aiCritiques(posts)
+	screenings := q.screenings(posts)
+	now := time.Now()
+	items := make([]queueItem, 0, len(posts))
+	for _, post := range posts {
+		submitted := post.SubmittedAt
+		if submitted == nil {
+			created := time.Time(post.CreatedAt)
+			submitted = &created
+		}
+		item := queueItem{
+			ID:            post.ID.String(),
+			Title:         post.Title,
+			Author:        gin.H{"id": post.User.ID, "name": post.User.Name},
+			Category:      gin.H{"id": post.Category.ID, "name": post.Category.Name},
+			AICritique:    critiques[post.ID.String()],
+			Screening:     screenings[post.ID.String()],
+			SubmittedAt:   submitted,
+			WaitingHours:  float64(int(now.Sub(*submitted).Hours()*10)) / 10,
+			ModeratorNote: post.ModeratorNote,
+		}
+		if post.HeadImg != "" {
+			item.Thumbnail = post.Images[imaging.Thumb]
+		}
+		if post.ClaimedBy != 0 && post.ClaimedAt != nil && now.Sub(*post.ClaimedAt) < claimTTL() {
+			item.ClaimedBy = post.ClaimedBy
+			item.ClaimedAt = post.ClaimedAt
+		}
+		items = append(items, item)
+	}
+
+	response.Success(ctx, gin.H{"data": items, "total": total}, "Success")
+}
+
+// aiCritiques 取出每个帖子最新的一条 AI 点评
+func (q ModerationQueueController) aiCritiques(posts []model.Post) map[string]string {
+	critiques := make(map[string]string, len(posts))
+	if len(posts) == 0 {
+		return critiques
+	}
+	ids := make([]string, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.ID.String())
+	}
+
+	var comments []model.Comment
+	q.DB.Joins("JOIN users ON users.id = comments.user_id").
+		Where("comments.post_id IN ? AND users.role = ?", ids, model.RoleAI).
+		Order("comments.created_at DESC").Find(&comments)
+	for _, comment := range comments {
+		if _, ok := critiques[comment.PostID.String()]; !ok {
+			critiques[comment.PostID.String()] = comment.Content
+		}
+	}
+	return critiques
+}
+
+// screenings 取出每个帖子最新的一次预审结果
+func (q ModerationQueueController) screenings(posts []model.Post) map[string]*model.PostScreening {
+	screenings := make(map[string]*model.PostScreening, len(posts))
+	if len(posts) == 0 {
+		return screenings
+	}
+	ids := make([]string, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.ID.String())
+	}
+
+	var rows []model.PostScreening
+	q.DB.Where("post_id IN ?", ids).Order("id DESC").Find(&rows)
+	for i := range rows {
+		if _, ok := screenings[rows[i].PostID.String()]; !ok {
+			screenings[rows[i].PostID.String()] = &rows[i]
+		}
+	}
+	return screenings
+}
+
+// Stats 返回队列长度、超过 SLA 的数量和最早一条的等待时间
+func (q ModerationQueueController) Stats(ctx *gin.Context) {
+	hours := slaHours(ctx)
+	deadline := time.Now().Add(-time.Duration(hours) * time.Hour)
+
+	var pending, overdue, claimed, flagged int64
+	pendingQueue(q.DB).Count(&pending)
+	pendingQueue(q.DB).Where("screening_verdict IN ?", []string{model.ScreeningReview, model.ScreeningUnsafe}).Count(&flagged)
+	pendingQueue(q.DB).Where("COALESCE(submitted_at, created_at) < ?", deadline).Count(&overdue)
+	pendingQueue(q.DB).Where("claimed_by <> 0 AND claimed_at >= ?", time.Now().Add(-claimTTL())).Count(&claimed)
+
+	oldestHours := 0.0
+	var oldest model.Post
+	if err := pendingQueue(q.DB).Order(queueOrder).First(&oldest).Error; err == nil {
+		submitted := time.Time(oldest.CreatedAt)
+		if oldest.SubmittedAt != nil {
+			submitted = *oldest.SubmittedAt
+		}
+		oldestHours = float64(int(time.Since(submitted).Hours()*10)) / 10
+	}
+
+	response.Success(ctx, gin.H{
+		"pending":      pending,
+		"claimed":      claimed,
+		"flagged":      flagged,
+		"sla_hours":    hours,
+		"overdue":      overdue,
+		"oldest_hours": oldestHours,
+	}, "Success")
+}
+
+// Claim 领取帖子，领取期间其他审核人员不能处理它；过期的领取可以被接手
+func (q ModerationQueueController) Claim(ctx *gin.Context) {
+	user, _ := ctx.Get("user")
+	userID := user.(model.User).ID
+	now := time.Now()
+
+	result := pendingQueue(q.DB).
+		Where("id = ? AND user_id <> ?", ctx.Param("id"), userID).
+		Where("claimed_by = 0 OR claimed_by = ? OR claimed_at IS NULL OR claimed_at < ?", userID, now.Add(-claimTTL())).
+		Updates(map[string]interface{}{"claimed_by": userID, "claimed_at": now})
+	if result.Error != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to claim post"}, "")
+		return
+	}
+	if result.RowsAffected == 0 {
+		var post model.Post
+		if err := q.DB.Where("id = ?", ctx.Param("id")).First(&post).Error; err != nil {
+			response.Fail(ctx, gin.H{"error": "Post does not exist"}, "")
+			return
+		}
+		if post.UserId == userID {
+			response.Fail(ctx, gin.H{"error": errSelfApprove.Error()}, "")
+			return
+		}
+		if post.Status != model.PostPending {
+			response.Fail(ctx, gin.H{"error": "Post is not waiting for review"}, "")
+			return
+		}
+		response.Fail(ctx, gin.H{"error": errPostClaimed.Error()}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"claimed_by": userID, "expires_at": now.Add(claimTTL())}, "Post claimed successfully")
+}
+
+// Release 放弃自己领取的帖子
+func (q ModerationQueueController) Release(ctx *gin.Context) {
+	user, _ := ctx.Get("user")
+	result := q.DB.Model(&model.Post{}).
+		Where("id = ? AND claimed_by = ?", ctx.Param("id"), user.(model.User).ID).
+		Updates(map[string]interface{}{"claimed_by": 0, "claimed_at": nil})
+	if result.Error != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to release post"}, "")
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Fail(ctx, gin.H{"error": "Post is not claimed by you"}, "")
+		return
+	}
+
+	response.Success(ctx, nil, "Post released successfully")
+}
+
+func (q ModerationQueueController) BulkApprove(ctx *gin.Context) {
+	q.bulk(ctx, model.PostApproved)
+}
+
+func (q ModerationQueueController) BulkReject(ctx *gin.Context) {
+	q.bulk(ctx, model.PostRejected)
+}
+
+// bulk 逐个处理帖子，单个失败不影响其他帖子，返回每个帖子的结果
+func (q ModerationQueueController) bulk(ctx *gin.Context, to string) {
+	var request vo.BulkModerationRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		response.Fail(ctx, gin.H{"error": "Data Error"}, "")
+		return
+	}
+	if to == model.PostRejected && request.Reason == "" {
+		response.Fail(ctx, gin.H{"error": "Rejection reason is required"}, "")
+		return
+	}
+
+	user, _ := ctx.Get("user")
+	moderation := vo.ModerationRequest{Reason: request.Reason, Note: request.Note}
+	results := make([]gin.H, 0, len(request.PostIDs))
+	succeeded := 0
+	for _, id := range request.PostIDs {
+		_, err := moderatePost(q.DB, user.(model.User), id, to, moderation)
+		switch {
+		case err == nil:
+			succeeded++
+			results = append(results, gin.H{"id": id, "status": to})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			results = append(results, gin.H{"id": id, "error": "Post does not exist"})
+		default:
+			results = append(results, gin.H{"id": id, "error": err.Error()})
+		}
+	}
+
+	response.Success(ctx, gin.H{
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(request.PostIDs) - succeeded,
+	}, "Bulk moderation finished")
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log"
+	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/model"
+	"owlllovo/ginessential/storage"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+var (
+	errAssetNotFound = errors.New("image does not exist, please upload it first")
+	errAssetNotOwned = errors.New("image was uploaded by another user")
+)
+
+// orphanAssetCondition 筛选没有帖子引用的上传；旧帖子只保存了 head_img，也算作引用
+const orphanAssetCondition = "NOT EXISTS (SELECT 1 FROM posts WHERE posts.asset_id = assets.id OR posts.head_img = assets.storage_key)"
+
+// resolveAsset 根据请求中的 asset_id 或 head_img 找到帖子要使用的上传，owner 是帖子作者，只能使用作者自己上传的图片。
+// current 是修改前的帖子，head_img 未变时沿用原来的图片（包括没有 Asset 记录的旧图片）。
+// 返回 nil 表示请求没有指定图片
+func resolveAsset(db *gorm.DB, owner uint, assetID uint, headImg string, current *model.Post) (*model.Asset, error) {
+	query := db
+	switch {
+	case assetID != 0:
+		if current != nil && current.AssetID != nil && *current.AssetID == assetID {
+			return current.Asset, nil
+		}
+		query = query.Where("id = ?", assetID)
+	case headImg != "":
+		if current != nil && current.HeadImg == headImg {
+			return current.Asset, nil
+		}
+		query = query.Where("storage_key = ?", headImg)
+	default:
+		return nil, nil
+	}
+
+	var asset model.Asset
+	if err := query.First(&asset).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errAssetNotFound
+		}
+		return nil, err
+	}
+	if asset.UserID != owner {
+		return nil, errAssetNotOwned
+	}
+	return &asset, nil
+}
+
+// AssetSweepOptions 对应 assets 配置
+type AssetSweepOptions struct {
+	// GracePeriod 是上传后等待帖子引用的时间，超过后没有引用的上传会被删除
+	GracePeriod time.Duration
+	Interval    time.Duration
+	BatchSize   int
+}
+
+func LoadAssetSweepOptions() AssetSweepOptions {
+	opts := AssetSweepOptions{
+		GracePeriod: viper.GetDuration("assets.gracePeriod"),
+		Interval:    viper.GetDuration("assets.sweepInterval"),
+		BatchSize:   viper.GetInt("assets.sweepBatch"),
+	}
+	if opts.GracePeriod <= 0 {
+		opts.GracePeriod = 24 * time.Hour
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	return opts
+}
+
+// SweepOrphanAssets 定期删除超过保留期且没有帖子引用的上传，包括删除帖子、更换封面后留下的图片
+func SweepOrphanAssets(opts AssetSweepOptions) {
+	for {
+		if removed, err := sweepOrphanAssets(context.Background(), common.GetDB(), storage.Default(), opts); err != nil {
+			log.Printf("Failed to sweep orphan assets: %v", err)
+		} else if removed > 0 {
+			log.Printf("Removed %d orphan assets", removed)
+		}
+		time.Sleep(opts.Interval)
+	}
+}
+
+func sweepOrphanAssets(ctx context.Context, db *gorm.DB, store storage.Storage, opts AssetSweepOptions) (int, error) {
+	cutoff := time.Now().Add(-opts.GracePeriod)
+	removed := 0
+	for {
+		var assets []model.Asset
+		if err := db.Where("created_at < ?", cutoff).Where(orphanAssetCondition).
+			Order("id ASC").Limit(opts.BatchSize).Find(&assets).Error; err != nil {
+			return removed, err
+		}
+
+		for _, asset := range assets {
+			// 先删除记录并再次确认没有引用，避免和刚好引用它的发帖请求冲突
+			result := db.Where("id = ?", asset.ID).Where(orphanAssetCondition).Delete(&model.Asset{})
+			if result.Error != nil {
+				return removed, result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			for _, key := range asset.Keys() {
+				if err := store.Delete(ctx, key); err != nil {
+					log.Printf("Failed to delete %s of asset %d: %v", key, asset.ID, err)
+				}
+			}
+			removed++
+		}
+
+		if len(assets) < opts.BatchSize {
+			return removed, nil
+		}
+	}
+}
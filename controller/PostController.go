@@ -1,20 +1,23 @@
 package controller
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
-	"image"
-	"image/jpeg"
+	"io"
 	"log"
 	"net/http"
-	"os"
 	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/imaging"
 	"owlllovo/ginessential/model"
 	"owlllovo/ginessential/response"
+	"owlllovo/ginessential/storage"
 	"owlllovo/ginessential/vo"
-	"path/filepath"
 	"strconv"
+	"time"
 
-	"github.com/fogleman/gg"
 	"github.com/gin-gonic/gin"
 	uuid "github.com/satori/go.uuid"
 	"gorm.io/gorm"
@@ -25,12 +28,17 @@ type IPostController interface {
 	PageList(ctx *gin.Context)
 	UploadImage(ctx *gin.Context)
 	ApprovePost(ctx *gin.Context)
+	RejectPost(ctx *gin.Context)
+	SubmitPost(ctx *gin.Context)
+	WithdrawPost(ctx *gin.Context)
+	ArchivePost(ctx *gin.Context)
+	ModerationHistory(ctx *gin.Context)
 	GetUserPosts(ctx *gin.Context)
 }
 
 func NewPostController() IPostController {
 	db := common.GetDB()
-	db.AutoMigrate(&model.Post{})
+	db.AutoMigrate(&model.Asset{}, &model.Post{}, &model.PostModeration{}, &model.PostScreening{}, &model.PostCritique{})
 	return PostController{DB: db}
 }
 
@@ -67,48 +75,79 @@ func (p PostController) Create(ctx *gin.Context) {
 
 	user, _ := ctx.Get("user")
 
+	// 封面只能使用自己上传的图片
+	asset, err := resolveAsset(p.DB, user.(model.User).ID, requestPost.AssetID, requestPost.HeadImg, nil)
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": err.Error()}, "")
+		return
+	}
+
 	// Create Post
 
+	// 新帖子默认直接提交审核，也可以先保存为草稿
+	status := model.PostPending
+	if requestPost.Status == model.PostDraft {
+		status = model.PostDraft
+	}
+
 	post := model.Post{
 		UserId:     user.(model.User).ID,
 		CategoryId: category.ID,
 		Title:      requestPost.Title,
-		HeadImg:    requestPost.HeadImg,
 		Content:    requestPost.Content,
-		Status:     "Pending",
+		ChildAge:   requestPost.ChildAge,
+		Status:     status,
 	}
-
-	if err := p.DB.Create(&post).Error; err != nil {
-		panic(err)
+	if asset != nil {
+		post.HeadImg = asset.StorageKey
+		post.AssetID = &asset.ID
+	}
+	if status == model.PostPending {
+		now := time.Now()
+		post.SubmittedAt = &now
 	}
 
-	response.Success(ctx, nil, "Create Success")
-
-	go func() {
-		var aiUser model.User
-		err := p.DB.Where("name = ?", "GPT-4").FirstOrCreate(&aiUser, model.User{Name: "GPT-4", Role: "AI"}).Error
-		if err != nil {
-			log.Printf("Failed to ensure AI user exists: %v", err)
-			return
+	// 草稿不生成 AI 点评，提交审核时再生成；超出 AI 限额时帖子照常发布，只是不生成 AI 点评
+	var quotaErr error
+	if status == model.PostPending {
+		if quotaErr = checkAIQuota(p.DB, user.(model.User)); quotaErr != nil {
+			log.Printf("Skipping AI critique for user %d: %v", post.UserId, quotaErr)
 		}
+	}
 
-		aiComment, err := GetGPTComment(requestPost.HeadImg, "请对这幅儿童绘画作品给出评价，从作品内容、构图、技巧等方面进行评价，给出不足之处并提出改进建议")
-
-		if err != nil {
-			log.Printf("Failed to get AI comment: %v", err)
-		} else {
-			// Assume the AI user has a fixed user ID, e.g., 1
-			aiUserComment := model.Comment{
-				PostID:  post.ID,
-				UserID:  aiUser.ID, // AI用户的ID
-				Content: aiComment,
-			}
-
-			if err := p.DB.Create(&aiUserComment).Error; err != nil {
-				log.Printf("Failed to save AI comment: %v", err)
-			}
+	// 帖子和 AI 点评任务在同一个事务中提交，保证每个帖子都会有点评任务
+	err = p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&post).Error; err != nil {
+			return err
 		}
-	}()
+		if err := tx.Create(&model.PostModeration{
+			PostID:   post.ID,
+			ToStatus: status,
+			ActorID:  post.UserId,
+		}).Error; err != nil {
+			return err
+		}
+		if status != model.PostPending {
+			return nil
+		}
+		if err := enqueueScreening(tx, post); err != nil {
+			return err
+		}
+		if quotaErr != nil {
+			return nil
+		}
+		_, err := enqueueCritique(tx, post, category, 0)
+		return err
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if quotaErr != nil {
+		response.Success(ctx, gin.H{"ai_critique": false, "reason": quotaErr.Error()}, "Create Success")
+		return
+	}
+	response.Success(ctx, nil, "Create Success")
 }
 
 func (p PostController) Update(ctx *gin.Context) {
@@ -143,7 +182,7 @@ func (p PostController) Update(ctx *gin.Context) {
 	*/
 
 	var post model.Post
-	result := p.DB.Where("id = ?", postId).First(&post)
+	result := p.DB.Preload("Asset").Where("id = ?", postId).First(&post)
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		response.Fail(ctx, gin.H{"error": "Post does not exist"}, "")
 		return
@@ -160,27 +199,64 @@ func (p PostController) Update(ctx *gin.Context) {
 	user, _ := ctx.Get("user")
 
 	userId := user.(model.User).ID
-	log.Println(user.(model.User).Role)
-	if userId != post.UserId && user.(model.User).Role != "Admin" {
+	if userId != post.UserId && !common.HasPermission(user.(model.User).Role, model.PermPostEditAny) {
 		response.Fail(ctx, gin.H{"error": "Only author and admin can edit posts"}, "")
 		return
 	}
 
-	// Update post
-	/* Tutorial Error, Original:
-	if err := p.DB.Model(&post).Update(requestPost).Error; err != nil {
-	*/
-	if err := p.DB.Model(&model.Post{}).Where("id = ?", postId).Updates(model.Post{
+	// 管理员修改别人的帖子时也只能使用作者上传的图片
+	asset, err := resolveAsset(p.DB, post.UserId, requestPost.AssetID, requestPost.HeadImg, &post)
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": err.Error()}, "")
+		return
+	}
+	updates := model.Post{
 		CategoryId: category.ID,
 		Title:      requestPost.Title,
 		HeadImg:    requestPost.HeadImg,
 		Content:    requestPost.Content,
-		Status:     requestPost.Status,
-	}).Error; err != nil {
+		ChildAge:   requestPost.ChildAge,
+	}
+	if asset != nil {
+		updates.HeadImg = asset.StorageKey
+		updates.AssetID = &asset.ID
+	}
+
+	// Update post
+	/* Tutorial Error, Original:
+	if err := p.DB.Model(&post).Update(requestPost).Error; err != nil {
+	*/
+	imageChanged := updates.HeadImg != "" && updates.HeadImg != post.HeadImg
+	err = p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Post{}).Where("id = ?", postId).Updates(updates).Error; err != nil {
+			return err
+		}
+		if !imageChanged {
+			return nil
+		}
+		post.HeadImg = updates.HeadImg
+		post.AssetID = updates.AssetID
+		// 待审核的帖子换了图片，之前的预审结论作废，重新预审
+		if post.Status != model.PostPending {
+			return nil
+		}
+		if err := tx.Model(&model.Post{}).Where("id = ?", postId).Update("screening_verdict", "").Error; err != nil {
+			return err
+		}
+		return enqueueScreening(tx, post)
+	})
+	if err != nil {
 		response.Fail(ctx, gin.H{"error": "Update Failed"}, "")
 		return
 	}
 
+	// 状态只能通过审核接口修改；作者修改已通过的帖子后需要重新审核
+	if userId == post.UserId && post.Status == model.PostApproved {
+		if err := transitionPost(p.DB, &post, model.PostPending, userId, "", ""); err != nil {
+			log.Printf("Failed to resubmit edited post %s: %v", post.ID, err)
+		}
+	}
+
 	response.Success(ctx, gin.H{"post": post}, "Update Success")
 
 }
@@ -190,20 +266,31 @@ func (p PostController) Show(ctx *gin.Context) {
 
 	var likeCount int64
 	p.DB.Model(&model.Like{}).Where("post_id = ?", postId).Count(&likeCount)
-	var post model.Post
 
 	// 使用Preload嵌套加载关联的评论以及评论的用户信息
-	result := p.DB.Preload("Category").Preload("Comments.User").Preload("User").Where("id = ?", postId).First(&post)
-	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+	post, err := findVisiblePost(p.DB.Preload("Category").Preload("Comments.User").Preload("User"), currentUser(ctx), postId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		response.Fail(ctx, gin.H{"error": "Post does not exist"}, "")
 		return
-	} else if result.Error != nil {
-		log.Println(result.Error)
+	} else if err != nil {
+		log.Println(err)
 		response.Fail(ctx, gin.H{"error": "An error occurred while retrieving the post"}, "")
 		return
 	}
 
-	response.Success(ctx, gin.H{"post": post, "likeCount": likeCount}, "Show Success")
+	// 最新一版 AI 点评，Structured 为 false 时没有评分；旧帖子为 null
+	var critique *model.PostCritique
+	var latest model.PostCritique
+	if err := p.DB.Where("post_id = ?", post.ID).Order("id DESC").First(&latest).Error; err == nil {
+		critique = &latest
+	}
+
+	response.Success(ctx, gin.H{
+		"post":       post,
+		"likeCount":  likeCount,
+		"critique":   critique,
+		"dimensions": critiqueDimensions,
+	}, "Show Success")
 }
 
 func (p PostController) Delete(ctx *gin.Context) {
@@ -225,7 +312,7 @@ func (p PostController) Delete(ctx *gin.Context) {
 	// Check if the logged-in user is the author of the post or an Admin
 	user, _ := ctx.Get("user")
 	userId := user.(model.User).ID
-	if userId != post.UserId && user.(model.User).Role != "Admin" {
+	if userId != post.UserId && !common.HasPermission(user.(model.User).Role, model.PermPostDeleteAny) {
 		response.Fail(ctx, gin.H{"error": "Post does not belong to you, access denied"}, "")
 		return
 	}
@@ -255,6 +342,21 @@ func (p PostController) Delete(ctx *gin.Context) {
 		return
 	}
 
+	// Delete AI critiques with their ratings, screening results and moderation history
+	critiques := tx.Model(&model.PostCritique{}).Select("id").Where("post_id = ?", postId)
+	if err := tx.Where("critique_id IN (?)", critiques).Delete(&model.CritiqueRating{}).Error; err != nil {
+		tx.Rollback()
+		response.Fail(ctx, gin.H{"error": "Failed to delete critique ratings"}, "")
+		return
+	}
+	for _, record := range []interface{}{&model.PostCritique{}, &model.PostScreening{}, &model.PostModeration{}} {
+		if err := tx.Where("post_id = ?", postId).Delete(record).Error; err != nil {
+			tx.Rollback()
+			response.Fail(ctx, gin.H{"error": "Failed to delete moderation records"}, "")
+			return
+		}
+	}
+
 	// Finally, delete the post
 	if err := tx.Delete(&post).Error; err != nil {
 		tx.Rollback()
@@ -263,6 +365,7 @@ func (p PostController) Delete(ctx *gin.Context) {
 	}
 
 	// Commit the transaction
+	// 帖子的图片不在这里删除，没有引用后由 SweepOrphanAssets 清理
 	tx.Commit()
 
 	response.Success(ctx, gin.H{"post": post}, "Delete Success")
@@ -275,100 +378,155 @@ func (p PostController) PageList(ctx *gin.Context) {
 
 	// Split into pages
 
+	visible := visiblePosts(currentUser(ctx))
+
 	var posts []model.Post
-	p.DB.Preload("Category").Preload("User").Order("created_at desc").Offset((pageNum - 1) * pageSize).Limit(pageSize).Find(&posts)
+	p.DB.Scopes(visible).Preload("Category").Preload("User").Order("created_at desc").Offset((pageNum - 1) * pageSize).Limit(pageSize).Find(&posts)
 
 	// Total numbers
 
 	var total int64
-	p.DB.Model(model.Post{}).Count(&total)
+	p.DB.Model(model.Post{}).Scopes(visible).Count(&total)
 
 	response.Success(ctx, gin.H{"data": posts, "total": total}, "Success")
 }
 
 func (p PostController) UploadImage(ctx *gin.Context) {
-	username := ctx.PostForm("username") // Retrieve the username from form data
+	// 水印中的作者名取自登录用户，不再信任表单中的 username
+	user, _ := ctx.Get("user")
+	author := user.(model.User)
+	opts := imaging.LoadOptions()
+
+	// 请求体只比文件上限多留一点给 multipart 的其它字段，避免先把超大请求整个收下来
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, opts.Limits.MaxBytes+1<<20)
 	file, err := ctx.FormFile("file")
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			uploadError(ctx, imaging.ErrFileTooLarge)
+			return
+		}
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if file.Size > opts.Limits.MaxBytes {
+		uploadError(ctx, imaging.ErrFileTooLarge)
+		return
+	}
 
-	// 生成唯一文件名
-	ext := filepath.Ext(file.Filename)
-	newFileName := uuid.NewV4().String() + ext
-
-	// 读取文件
+	// 读取文件，最多读 MaxBytes+1 字节，超出部分由 Validate 拒绝
 	srcFile, err := file.Open()
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open the file"})
 		return
 	}
 	defer srcFile.Close()
-	// 解码图片
-	img, _, err := image.Decode(srcFile)
+	data, err := io.ReadAll(io.LimitReader(srcFile, opts.Limits.MaxBytes+1))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode the image"})
-		return
-	}
-
-	// 添加水印
-	dc := gg.NewContextForImage(img)
-	dc.SetRGBA(1, 1, 1, 0.5) // 设置水印颜色和透明度
-
-	// Set the font size - increase this to make the watermark larger
-	if err := dc.LoadFontFace("assets/fonts/MS.ttf", 50); err != nil { // Load your own font with the desired size
-		log.Println(2)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load font face"})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read the file"})
 		return
 	}
 
-	dc.DrawStringAnchored(username, float64(dc.Width())/2, float64(dc.Height())/2, 0.5, 0.5) // 中心位置
-	dc.Stroke()
-
-	// 创建文件路径
-	path := "assets/images/" + newFileName
-	dstFile, err := os.Create(path)
+	// 转正、缩小、加水印并生成各个尺寸
+	watermark := imaging.Watermark(imaging.LoadWatermarkOptions(), imaging.WatermarkData{
+		Author:   author.Name,
+		AuthorID: author.ID,
+	})
+	result, err := imaging.Process(data, opts, watermark)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create the file"})
+		log.Printf("Failed to process upload: %v", err)
+		uploadError(ctx, err)
 		return
 	}
-	defer dstFile.Close()
 
-	// 保存修改后的图片
-	if err := jpeg.Encode(dstFile, dc.Image(), &jpeg.Options{Quality: 100}); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save the watermarked image"})
-		return
+	// 生成唯一文件名，保存到配置的存储后端
+	base := uuid.NewV4().String()
+	store := storage.Default()
+	var size int64
+	var written []string
+	// 保存失败时删除已经写入的文件，没有 Asset 记录的文件清理任务找不到
+	cleanup := func() {
+		for _, key := range written {
+			if err := store.Delete(context.Background(), key); err != nil {
+				log.Printf("Failed to remove %s after failed upload: %v", key, err)
+			}
+		}
 	}
-
-	// 返回保存的文件名
-	ctx.JSON(http.StatusOK, gin.H{"filename": newFileName})
-}
-
-// 在 PostController 中添加 ApprovePost 方法
-func (p PostController) ApprovePost(ctx *gin.Context) {
-
-	postId := ctx.Param("id") // 获取URL参数中的postId
-
-	var post model.Post
-	// 查找帖子，确保它存在
-	if err := p.DB.Where("id = ?", postId).First(&post).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			response.Fail(ctx, gin.H{"error": "Post not found"}, "Post does not exist")
+	for rendition, content := range result.Files {
+		key := imaging.FileName(base, rendition)
+		if err := store.Put(ctx.Request.Context(), key, bytes.NewReader(content), int64(len(content)), "image/jpeg"); err != nil {
+			log.Printf("Failed to store %s: %v", key, err)
+			cleanup()
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save the watermarked image"})
 			return
 		}
-		response.Fail(ctx, gin.H{"error": err.Error()}, "An error occurred while retrieving the post")
-		return
+		written = append(written, key)
+		size += int64(len(content))
 	}
 
-	// 更改帖子的状态为"Approved"
-	post.Status = "Approved"
-	if err := p.DB.Save(&post).Error; err != nil {
-		response.Fail(ctx, gin.H{"error": err.Error()}, "Failed to approve the post")
+	// 记录这次上传，创建帖子时提交 asset_id（旧客户端提交 filename 作为 head_img）
+	sum := sha256.Sum256(data)
+	asset := model.Asset{
+		UserID:     author.ID,
+		StorageKey: imaging.FileName(base, imaging.Original),
+		Size:       size,
+		Width:      result.Width,
+		Height:     result.Height,
+		MIME:       result.Source.MIME,
+		Hash:       hex.EncodeToString(sum[:]),
+		PHash:      result.PHash,
+	}
+	if err := p.DB.Create(&asset).Error; err != nil {
+		log.Printf("Failed to record asset %s: %v", asset.StorageKey, err)
+		cleanup()
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save the watermarked image"})
 		return
 	}
 
-	response.Success(ctx, gin.H{"post": post}, "Post approved successfully")
+	// 与已有上传相似时只提示，不阻止上传
+	duplicates := duplicateWarning(p.DB, asset)
+
+	newFileName := asset.StorageKey
+	ctx.JSON(http.StatusOK, gin.H{
+		"asset_id": asset.ID,
+		"filename": newFileName,
+		"images":   imaging.URLs(newFileName),
+		"width":    result.Width,
+		"height":   result.Height,
+		"source": gin.H{
+			"mime":   result.Source.MIME,
+			"width":  result.Source.Width,
+			"height": result.Source.Height,
+		},
+		"duplicates": duplicates,
+	})
+}
+
+// uploadError 把图片校验错误转换为对应的 4xx 响应，其它错误返回 500
+func uploadError(ctx *gin.Context, err error) {
+	limits := imaging.LoadLimits()
+	switch {
+	case errors.Is(err, imaging.ErrFileTooLarge):
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":     "File is too large",
+			"max_bytes": limits.MaxBytes,
+		})
+	case errors.Is(err, imaging.ErrUnsupportedType):
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":         "Unsupported file type",
+			"allowed_types": limits.AllowedTypes,
+		})
+	case errors.Is(err, imaging.ErrDimensionsTooLarge):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":         "Image dimensions are too large",
+			"max_dimension": limits.MaxDimension,
+			"max_pixels":    limits.MaxPixels,
+		})
+	case errors.Is(err, imaging.ErrInvalidImage):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "File is not a valid image"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process the image"})
+	}
 }
 
 func (p PostController) GetUserPosts(ctx *gin.Context) {
@@ -385,8 +543,11 @@ func (p PostController) GetUserPosts(ctx *gin.Context) {
 		pageSize = 10
 	}
 
+	visible := visiblePosts(currentUser(ctx))
+
 	var posts []model.Post
 	if err := p.DB.
+		Scopes(visible).
 		Preload("Category").
 		Preload("User").
 		Where("user_id = ?", userId).
@@ -404,7 +565,7 @@ func (p PostController) GetUserPosts(ctx *gin.Context) {
 	}
 
 	var total int64
-	p.DB.Model(&model.Post{}).Where("user_id = ?", userId).Count(&total)
+	p.DB.Model(&model.Post{}).Scopes(visible).Where("user_id = ?", userId).Count(&total)
 
 	response.Success(ctx, gin.H{"userName": user.Name, "posts": posts, "total": total, "pageNum": pageNum, "pageSize": pageSize}, "User's posts retrieved successfully")
 }
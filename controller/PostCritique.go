@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"owlllovo/ginessential/model"
+	"owlllovo/ginessential/queue"
+	"owlllovo/ginessential/repository"
+	"strings"
+	"text/template"
+
+	"gorm.io/gorm"
+)
+
+// defaultCritiqueTemplate 是数据库中没有匹配的提示词模板时使用的默认模板
+const defaultCritiqueTemplate = `请对这幅{{if .Age}}{{.Age}} 岁儿童{{else}}儿童{{end}}的绘画作品《{{.Title}}》给出评价，` +
+	`从作品内容、构图、色彩、技巧和创意五个维度打分（1 到 10 的整数），总结作品的优点，指出不足之处并提出改进建议。`
+
+// critiqueFormat 总是追加在模板之后，保证模型按结构化格式输出
+const critiqueFormat = `只输出一个 JSON 对象，不要输出其他文字，格式为：` +
+	`{"scores": {"content": 整数, "composition": 整数, "color": 整数, "technique": 整数, "creativity": 整数}, ` +
+	`"summary": "一段完整的中文评价", "strengths": ["优点", ...], "suggestions": ["改进建议", ...]}`
+
+// PromptData 是提示词模板中可以使用的变量
+type PromptData struct {
+	Title    string
+	Content  string
+	Category string
+	Age      int
+}
+
+// parsePromptTemplate 解析模板，缺少字段等错误会在保存模板时暴露
+func parsePromptTemplate(body string) (*template.Template, error) {
+	return template.New("prompt").Option("missingkey=error").Parse(body)
+}
+
+// renderPrompt 渲染模板并追加输出格式说明
+func renderPrompt(body string, data PromptData) (string, error) {
+	tmpl, err := parsePromptTemplate(body)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()) + "\n" + critiqueFormat, nil
+}
+
+// critiquePrompt 选出适用于帖子的模板并渲染，模板出错时退回默认模板；
+// 返回的 templateID 为 0 表示使用的是默认模板
+func critiquePrompt(db *gorm.DB, post model.Post, category model.Category) (prompt string, templateID uint, version int) {
+	data := PromptData{Title: post.Title, Content: post.Content, Category: category.Name, Age: post.ChildAge}
+
+	tmpl, err := repository.PromptRepository{DB: db}.Match(category.ID, post.ChildAge)
+	if err != nil {
+		log.Printf("Failed to load prompt templates: %v", err)
+	}
+	if tmpl != nil {
+		if prompt, err := renderPrompt(tmpl.Body, data); err == nil {
+			return prompt, tmpl.ID, tmpl.Version
+		}
+		log.Printf("Prompt template %d v%d failed to render: %v", tmpl.ID, tmpl.Version, err)
+	}
+
+	prompt, _ = renderPrompt(defaultCritiqueTemplate, data)
+	return prompt, 0, 0
+}
+
+// critiqueInFlight 判断帖子是否已有排队或执行中的点评任务
+func critiqueInFlight(db *gorm.DB, post model.Post) bool {
+	var inFlight int64
+	db.Model(&model.Job{}).
+		Where("type = ? AND status IN ? AND payload LIKE ?", JobPostAIComment,
+			[]string{model.JobPending, model.JobRunning}, `%"post_id":"`+post.ID.String()+`"%`).
+		Count(&inFlight)
+	return inFlight > 0
+}
+
+// enqueueCritique 生成提示词并把 AI 点评任务放入队列，requestedBy 为 0 时用量记在作者名下
+func enqueueCritique(tx *gorm.DB, post model.Post, category model.Category, requestedBy uint) (*model.Job, error) {
+	prompt, templateID, version := critiquePrompt(tx, post, category)
+	return queue.Enqueue(tx, JobPostAIComment, PostAICommentJob{
+		PostID:           post.ID.String(),
+		Prompt:           prompt,
+		PromptTemplateID: templateID,
+		PromptVersion:    version,
+		RequestedBy:      requestedBy,
+	})
+}
+
+// enqueueFirstCritique 在草稿第一次提交审核时生成 AI 点评；已有点评或点评任务时跳过，
+// 作者超出限额时只打印日志，不影响提交
+func enqueueFirstCritique(tx *gorm.DB, post model.Post) error {
+	var critiques int64
+	if err := tx.Model(&model.PostCritique{}).Where("post_id = ?", post.ID).Count(&critiques).Error; err != nil {
+		return err
+	}
+	if critiques > 0 || critiqueInFlight(tx, post) {
+		return nil
+	}
+	if err := checkAIQuotaForUser(tx, post.UserId); err != nil {
+		log.Printf("Skipping AI critique for post %s: %v", post.ID, err)
+		return nil
+	}
+
+	var category model.Category
+	if err := tx.First(&category, post.CategoryId).Error; err != nil {
+		return err
+	}
+	_, err := enqueueCritique(tx, post, category, 0)
+	return err
+}
+
+// critiqueDimensions 是评分维度，顺序即雷达图的顺序
+var critiqueDimensions = []string{"content", "composition", "color", "technique", "creativity"}
+
+var errInvalidCritique = errors.New("invalid structured critique")
+
+// critiqueResponse 是模型按 critiquePrompt 生成的提示词返回的结构
+type critiqueResponse struct {
+	Scores      map[string]int `json:"scores"`
+	Summary     string         `json:"summary"`
+	Strengths   []string       `json:"strengths"`
+	Suggestions []string       `json:"suggestions"`
+}
+
+// parseCritique 从模型输出中取出 JSON 并按评分规则校验
+func parseCritique(content string) (*model.PostCritique, error) {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("%w: no JSON object found", errInvalidCritique)
+	}
+
+	var resp critiqueResponse
+	if err := json.Unmarshal([]byte(content[start:end+1]), &resp); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidCritique, err)
+	}
+
+	for _, dimension := range critiqueDimensions {
+		score, ok := resp.Scores[dimension]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing score %q", errInvalidCritique, dimension)
+		}
+		if score < 1 || score > 10 {
+			return nil, fmt.Errorf("%w: score %q out of range: %d", errInvalidCritique, dimension, score)
+		}
+	}
+	resp.Summary = strings.TrimSpace(resp.Summary)
+	if resp.Summary == "" {
+		return nil, fmt.Errorf("%w: summary is empty", errInvalidCritique)
+	}
+	resp.Strengths = nonEmpty(resp.Strengths)
+	resp.Suggestions = nonEmpty(resp.Suggestions)
+	if len(resp.Suggestions) == 0 {
+		return nil, fmt.Errorf("%w: suggestions are empty", errInvalidCritique)
+	}
+
+	return &model.PostCritique{
+		Content:     resp.Scores["content"],
+		Composition: resp.Scores["composition"],
+		Color:       resp.Scores["color"],
+		Technique:   resp.Scores["technique"],
+		Creativity:  resp.Scores["creativity"],
+		Summary:     resp.Summary,
+		Strengths:   resp.Strengths,
+		Suggestions: resp.Suggestions,
+	}, nil
+}
+
+func nonEmpty(items []string) []string {
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// critiqueText 把结构化点评转换成评论区显示的文字
+func critiqueText(critique *model.PostCritique) string {
+	var b strings.Builder
+	b.WriteString(critique.Summary)
+	if len(critique.Strengths) > 0 {
+		b.WriteString("\n\n优点：")
+		for _, item := range critique.Strengths {
+			b.WriteString("\n- " + item)
+		}
+	}
+	b.WriteString("\n\n改进建议：")
+	for _, item := range critique.Suggestions {
+		b.WriteString("\n- " + item)
+	}
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseCritique(t *testing.T) {
+	const valid = `{"scores": {"content": 8, "composition": 7, "color": 9, "technique": 6, "creativity": 10}, ` +
+		`"summary": " 色彩很大胆 ", "strengths": ["用色鲜艳", " "], "suggestions": ["注意远近关系"]}`
+
+	t.Run("valid", func(t *testing.T) {
+		critique, err := parseCritique(valid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scores := []int{critique.Content, critique.Composition, critique.Color, critique.Technique, critique.Creativity}
+		if !reflect.DeepEqual(scores, []int{8, 7, 9, 6, 10}) {
+			t.Errorf("scores = %v", scores)
+		}
+		if critique.Summary != "色彩很大胆" {
+			t.Errorf("Summary = %q", critique.Summary)
+		}
+		if !reflect.DeepEqual(critique.Strengths, []string{"用色鲜艳"}) {
+			t.Errorf("Strengths = %q, want blank items removed", critique.Strengths)
+		}
+		if !reflect.DeepEqual(critique.Suggestions, []string{"注意远近关系"}) {
+			t.Errorf("Suggestions = %q", critique.Suggestions)
+		}
+	})
+
+	t.Run("surrounded by text", func(t *testing.T) {
+		if _, err := parseCritique("好的，以下是点评：\n```json\n" + valid + "\n```"); err != nil {
+			t.Errorf("parseCritique = %v", err)
+		}
+	})
+
+	invalid := []struct {
+		name    string
+		content string
+	}{
+		{"plain text", "这幅画很棒，色彩鲜艳。"},
+		{"malformed json", `{"scores": {"content": 8,}`},
+		{"missing score", `{"scores": {"content": 8, "composition": 7, "color": 9, "technique": 6}, "summary": "s", "suggestions": ["a"]}`},
+		{"score too low", `{"scores": {"content": 0, "composition": 7, "color": 9, "technique": 6, "creativity": 10}, "summary": "s", "suggestions": ["a"]}`},
+		{"score too high", `{"scores": {"content": 8, "composition": 11, "color": 9, "technique": 6, "creativity": 10}, "summary": "s", "suggestions": ["a"]}`},
+		{"empty summary", `{"scores": {"content": 8, "composition": 7, "color": 9, "technique": 6, "creativity": 10}, "summary": "  ", "suggestions": ["a"]}`},
+		{"blank suggestions", `{"scores": {"content": 8, "composition": 7, "color": 9, "technique": 6, "creativity": 10}, "summary": "s", "suggestions": [""]}`},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseCritique(tt.content); !errors.Is(err, errInvalidCritique) {
+				t.Errorf("parseCritique = %v, want errInvalidCritique", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"owlllovo/ginessential/ai"
+	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/model"
+	"owlllovo/ginessential/queue"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const JobPostScreening = "post_screening"
+
+const screeningPrompt = `你是儿童绘画社区的内容审核员。请判断这张图片是否适合在面向儿童的社区中公开展示，` +
+	`检查是否包含暴力、血腥、色情、仇恨符号、个人隐私信息（如真实姓名、住址、电话、人脸照片）或与绘画无关的内容。` +
+	`只输出一个 JSON 对象，不要输出其他文字，格式为：` +
+	`{"verdict": "safe" | "review" | "unsafe", "confidence": 0 到 1 之间的小数, "reasons": ["理由", ...]}。` +
+	`明确是正常儿童画作时 verdict 为 safe；无法确定时为 review；明显不适合时为 unsafe。`
+
+func init() {
+	queue.Register(JobPostScreening, handlePostScreening)
+}
+
+// PostScreeningJob 是 JobPostScreening 任务的 payload，HeadImg 是入队时帖子的图片
+type PostScreeningJob struct {
+	PostID  string `json:"post_id"`
+	HeadImg string `json:"head_img"`
+}
+
+// ScreeningOptions 是 moderation.screening 下的配置
+type ScreeningOptions struct {
+	Enabled bool
+	// Provider 为空时使用默认的 AI provider（含 failover）
+	Provider string
+	// AutoApprove 开启后，置信度不低于 MinConfidence 的 safe 结论会直接通过审核
+	AutoApprove   bool
+	MinConfidence float64
+}
+
+func loadScreeningOptions() ScreeningOptions {
+	opts := ScreeningOptions{
+		Enabled:       true,
+		Provider:      viper.GetString("moderation.screening.provider"),
+		AutoApprove:   viper.GetBool("moderation.screening.autoApprove"),
+		MinConfidence: viper.GetFloat64("moderation.screening.minConfidence"),
+	}
+	if viper.IsSet("moderation.screening.enabled") {
+		opts.Enabled = viper.GetBool("moderation.screening.enabled")
+	}
+	if opts.MinConfidence <= 0 || opts.MinConfidence > 1 {
+		opts.MinConfidence = 0.9
+	}
+	return opts
+}
+
+// enqueueScreening 在帖子进入待审核或待审核的帖子更换图片时加入预审任务，需要和帖子的修改在同一个事务中调用
+func enqueueScreening(tx *gorm.DB, post model.Post) error {
+	if post.HeadImg == "" || !loadScreeningOptions().Enabled {
+		return nil
+	}
+	_, err := queue.Enqueue(tx, JobPostScreening, PostScreeningJob{PostID: post.ID.String(), HeadImg: post.HeadImg})
+	return err
+}
+
+// screeningResult 是模型按 screeningPrompt 返回的结构
+type screeningResult struct {
+	Verdict    string   `json:"verdict"`
+	Confidence float64  `json:"confidence"`
+	Reasons    []string `json:"reasons"`
+}
+
+// parseScreening 从模型输出中取出 JSON 结论；无法解析时交给人工审核
+func parseScreening(content string) screeningResult {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	var result screeningResult
+	if start < 0 || end <= start || json.Unmarshal([]byte(content[start:end+1]), &result) != nil {
+		return screeningResult{Verdict: model.ScreeningReview, Reasons: []string{"unparseable screening response"}}
+	}
+
+	result.Verdict = strings.ToLower(strings.TrimSpace(result.Verdict))
+	switch result.Verdict {
+	case model.ScreeningSafe, model.ScreeningReview, model.ScreeningUnsafe:
+	default:
+		result.Reasons = append(result.Reasons, fmt.Sprintf("unknown verdict %q", result.Verdict))
+		result.Verdict = model.ScreeningReview
+	}
+	if result.Confidence < 0 {
+		result.Confidence = 0
+	}
+	if result.Confidence > 1 {
+		result.Confidence = 1
+	}
+	return result
+}
+
+// screenImage 把图片交给配置的视觉模型做安全预审
+func screenImage(ctx context.Context, db *gorm.DB, opts ScreeningOptions, post model.Post) (screeningResult, *ai.Result, error) {
+	image, err := readPostImage(post.HeadImg)
+	if err != nil {
+		return screeningResult{}, nil, err
+	}
+
+	critic := ai.Default()
+	if opts.Provider != "" {
+		if provider, ok := ai.Provider(opts.Provider); ok {
+			critic = provider
+		}
+	}
+
+	result, err := meteredCritique(ctx, db, critic, ai.Request{Prompt: screeningPrompt, Image: image, Task: ai.TaskScreening},
+		usageMeta{UserID: post.UserId, PostID: post.ID, Purpose: model.UsagePostScreening})
+	if err != nil {
+		return screeningResult{}, nil, err
+	}
+	return parseScreening(result.Content), result, nil
+}
+
+func handlePostScreening(job *model.Job) error {
+	var payload PostScreeningJob
+	if err := queue.Decode(job, &payload); err != nil {
+		return err
+	}
+
+	db := common.GetDB()
+	var post model.Post
+	if err := db.Where("id = ?", payload.PostID).First(&post).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Post %s was deleted before it was screened", payload.PostID)
+			return nil
+		}
+		return err
+	}
+	// 预审完成前帖子已经被人工处理或撤回
+	if post.Status != model.PostPending {
+		return nil
+	}
+	// 排队期间帖子换了图片，新图片有自己的预审任务
+	if payload.HeadImg != "" && payload.HeadImg != post.HeadImg {
+		return nil
+	}
+
+	opts := loadScreeningOptions()
+	verdict, result, err := screenImage(context.Background(), db, opts, post)
+	if err != nil {
+		return err
+	}
+
+	screening := model.PostScreening{
+		PostID:     post.ID,
+		HeadImg:    post.HeadImg,
+		AssetID:    post.AssetID,
+		Verdict:    verdict.Verdict,
+		Confidence: verdict.Confidence,
+		Reasons:    verdict.Reasons,
+		Provider:   result.Provider,
+		Model:      result.Model,
+		Raw:        result.Content,
+	}
+	screening.AutoApproved = opts.AutoApprove && verdict.Verdict == model.ScreeningSafe &&
+		verdict.Confidence >= opts.MinConfidence
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&screening).Error; err != nil {
+			return err
+		}
+		// 预审期间帖子换了图片时不更新结论，等新图片的预审
+		return tx.Model(&model.Post{}).Where("id = ? AND head_img = ?", post.ID, screening.HeadImg).
+			Update("screening_verdict", verdict.Verdict).Error
+	}); err != nil {
+		return err
+	}
+
+	if !screening.AutoApproved || !model.CanTransitionPost(post.Status, model.PostApproved, model.ActorSystem) {
+		log.Printf("Post %s flagged for review: %s (%.2f) %v", post.ID, verdict.Verdict, verdict.Confidence, verdict.Reasons)
+		return nil
+	}
+
+	aiUser, err := ensureAIUser(db)
+	if err != nil {
+		return fmt.Errorf("failed to ensure AI user exists: %w", err)
+	}
+	note := fmt.Sprintf("auto-approved by AI pre-screening (confidence %.2f)", verdict.Confidence)
+	// 只有帖子的图片仍然是预审过的那张时才自动通过
+	if err := transitionPostWhere(db, &post, model.PostApproved, aiUser.ID, "", note,
+		"head_img = ?", screening.HeadImg); err != nil {
+		// 人工审核抢先处理了帖子，或者帖子已经换了图片
+		if errors.Is(err, errPostStatusChanged) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
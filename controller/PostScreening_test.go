@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"owlllovo/ginessential/model"
+	"testing"
+)
+
+func TestParseScreening(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		verdict     string
+		confidence  float64
+		wantReasons bool
+	}{
+		{"safe", `{"verdict": "safe", "confidence": 0.95, "reasons": []}`, model.ScreeningSafe, 0.95, false},
+		{"unsafe with reasons", `{"verdict": "unsafe", "confidence": 0.8, "reasons": ["包含真实姓名"]}`, model.ScreeningUnsafe, 0.8, true},
+		{"surrounded by text", "结论如下：```json\n{\"verdict\": \"review\", \"confidence\": 0.5}\n```", model.ScreeningReview, 0.5, false},
+		{"verdict case and spaces", `{"verdict": " Safe ", "confidence": 0.9}`, model.ScreeningSafe, 0.9, false},
+		{"unknown verdict", `{"verdict": "ok", "confidence": 0.99}`, model.ScreeningReview, 0.99, true},
+		{"confidence above one", `{"verdict": "safe", "confidence": 3}`, model.ScreeningSafe, 1, false},
+		{"negative confidence", `{"verdict": "safe", "confidence": -0.5}`, model.ScreeningSafe, 0, false},
+		{"plain text", "这张图片是正常的儿童画作", model.ScreeningReview, 0, true},
+		{"malformed json", `{"verdict": "safe",}`, model.ScreeningReview, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseScreening(tt.content)
+			if got.Verdict != tt.verdict || got.Confidence != tt.confidence {
+				t.Errorf("parseScreening = %q (%v), want %q (%v)", got.Verdict, got.Confidence, tt.verdict, tt.confidence)
+			}
+			if hasReasons := len(got.Reasons) > 0; hasReasons != tt.wantReasons {
+				t.Errorf("Reasons = %q, want reasons: %v", got.Reasons, tt.wantReasons)
+			}
+		})
+	}
+}
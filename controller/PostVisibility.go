@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// moderatedStatuses 是审核人员可以看到的非公开状态，草稿只有作者可见
+var moderatedStatuses = []string{model.PostApproved, model.PostPending, model.PostRejected, model.PostArchived}
+
+// currentUser 返回已登录的用户，匿名访问时返回 nil
+func currentUser(ctx *gin.Context) *model.User {
+	user, exists := ctx.Get("user")
+	if !exists {
+		return nil
+	}
+	u := user.(model.User)
+	return &u
+}
+
+func isModerator(user *model.User) bool {
+	return user != nil && common.HasPermission(user.Role, model.PermPostApprove)
+}
+
+// canViewPost 已通过的帖子所有人可见，草稿只有作者可见，其它状态作者和审核人员可见
+func canViewPost(user *model.User, post model.Post) bool {
+	if post.Status == model.PostApproved {
+		return true
+	}
+	if user == nil {
+		return false
+	}
+	if user.ID == post.UserId {
+		return true
+	}
+	return post.Status != model.PostDraft && isModerator(user)
+}
+
+// findVisiblePost 查询 user 可以查看的帖子，不存在或无权查看时都返回 gorm.ErrRecordNotFound；
+// 需要关联数据时传入带 Preload 的 db
+func findVisiblePost(db *gorm.DB, user *model.User, postId string) (*model.Post, error) {
+	var post model.Post
+	if err := db.Where("id = ?", postId).First(&post).Error; err != nil {
+		return nil, err
+	}
+	if !canViewPost(user, post) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &post, nil
+}
+
+// visiblePosts 是与 canViewPost 规则一致的查询 scope
+func visiblePosts(user *model.User) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch {
+		case user == nil:
+			return db.Where("posts.status = ?", model.PostApproved)
+		case isModerator(user):
+			return db.Where("posts.status IN ? OR posts.user_id = ?", moderatedStatuses, user.ID)
+		default:
+			return db.Where("posts.status = ? OR posts.user_id = ?", model.PostApproved, user.ID)
+		}
+	}
+}
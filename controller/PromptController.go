@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"owlllovo/ginessential/model"
+	"owlllovo/ginessential/repository"
+	"owlllovo/ginessential/response"
+	"owlllovo/ginessential/vo"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IPromptController interface {
+	RestController
+	ListAll(ctx *gin.Context)
+	Versions(ctx *gin.Context)
+	Rollback(ctx *gin.Context)
+	Preview(ctx *gin.Context)
+}
+
+// PromptController 管理 AI 点评的提示词模板
+type PromptController struct {
+	Repository repository.PromptRepository
+}
+
+func NewPromptController() IPromptController {
+	repo := repository.NewPromptRepository()
+	repo.DB.AutoMigrate(&model.PromptTemplate{}, &model.PromptTemplateVersion{})
+	return PromptController{Repository: repo}
+}
+
+// bindTemplate 校验请求，模板必须能用示例数据渲染
+func (p PromptController) bindTemplate(ctx *gin.Context) (*model.PromptTemplate, bool) {
+	var request vo.PromptTemplateRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		response.Fail(ctx, gin.H{"error": "Data Error, Please Fill Name and Body"}, "")
+		return nil, false
+	}
+	if request.MaxAge != 0 && request.MinAge > request.MaxAge {
+		response.Fail(ctx, gin.H{"error": "min_age cannot be greater than max_age"}, "")
+		return nil, false
+	}
+	if request.CategoryID != 0 {
+		if err := p.Repository.DB.First(&model.Category{}, request.CategoryID).Error; err != nil {
+			response.Fail(ctx, gin.H{"error": "Category does not exist"}, "")
+			return nil, false
+		}
+	}
+	if _, err := renderPrompt(request.Body, samplePromptData()); err != nil {
+		response.Fail(ctx, gin.H{"error": "Invalid template: " + err.Error()}, "")
+		return nil, false
+	}
+
+	active := true
+	if request.Active != nil {
+		active = *request.Active
+	}
+	user, _ := ctx.Get("user")
+	return &model.PromptTemplate{
+		Name:       request.Name,
+		CategoryID: request.CategoryID,
+		MinAge:     request.MinAge,
+		MaxAge:     request.MaxAge,
+		Body:       request.Body,
+		Active:     active,
+		UpdatedBy:  user.(model.User).ID,
+	}, true
+}
+
+func samplePromptData() PromptData {
+	return PromptData{Title: "我的家", Content: "我画了爸爸妈妈和我", Category: "水彩", Age: 7}
+}
+
+func (p PromptController) Create(ctx *gin.Context) {
+	template, ok := p.bindTemplate(ctx)
+	if !ok {
+		return
+	}
+
+	created, err := p.Repository.Create(*template)
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Create Failed"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"template": created}, "Create Success")
+}
+
+func (p PromptController) Update(ctx *gin.Context) {
+	templateId, _ := strconv.Atoi(ctx.Params.ByName("id"))
+	if _, err := p.Repository.SelectById(uint(templateId)); err != nil {
+		response.Fail(ctx, gin.H{"error": "Template does not exist"}, "")
+		return
+	}
+
+	template, ok := p.bindTemplate(ctx)
+	if !ok {
+		return
+	}
+
+	updated, err := p.Repository.Update(uint(templateId), *template)
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Update Failed"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"template": updated}, "Update Success")
+}
+
+func (p PromptController) Show(ctx *gin.Context) {
+	templateId, _ := strconv.Atoi(ctx.Params.ByName("id"))
+
+	template, err := p.Repository.SelectById(uint(templateId))
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Template does not exist"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"template": template}, "")
+}
+
+func (p PromptController) Delete(ctx *gin.Context) {
+	templateId, _ := strconv.Atoi(ctx.Params.ByName("id"))
+
+	if _, err := p.Repository.SelectById(uint(templateId)); err != nil {
+		response.Fail(ctx, gin.H{"error": "Template does not exist"}, "")
+		return
+	}
+	if err := p.Repository.DeleteById(uint(templateId)); err != nil {
+		response.Fail(ctx, gin.H{"error": "Delete Failed"}, "")
+		return
+	}
+
+	response.Success(ctx, nil, "")
+}
+
+func (p PromptController) ListAll(ctx *gin.Context) {
+	templates, err := p.Repository.ListAll()
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to retrieve templates"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"templates": templates, "default": defaultCritiqueTemplate}, "")
+}
+
+func (p PromptController) Versions(ctx *gin.Context) {
+	templateId, _ := strconv.Atoi(ctx.Params.ByName("id"))
+
+	versions, err := p.Repository.Versions(uint(templateId))
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to retrieve versions"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"versions": versions}, "")
+}
+
+// Rollback 把指定的历史版本重新发布为最新版本
+func (p PromptController) Rollback(ctx *gin.Context) {
+	var request vo.PromptRollbackRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		response.Fail(ctx, gin.H{"error": "Data Error, Please Fill Version"}, "")
+		return
+	}
+
+	templateId, _ := strconv.Atoi(ctx.Params.ByName("id"))
+	user, _ := ctx.Get("user")
+	template, err := p.Repository.Rollback(uint(templateId), request.Version, user.(model.User).ID)
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Template or version does not exist"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"template": template}, "Rollback Success")
+}
+
+// Preview 渲染模板但不保存，方便老师调整措辞
+func (p PromptController) Preview(ctx *gin.Context) {
+	var request vo.PromptPreviewRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		response.Fail(ctx, gin.H{"error": "Data Error, Please Fill Body"}, "")
+		return
+	}
+
+	data := samplePromptData()
+	if request.Title != "" {
+		data.Title = request.Title
+	}
+	if request.Content != "" {
+		data.Content = request.Content
+	}
+	if request.Category != "" {
+		data.Category = request.Category
+	}
+	if request.Age != 0 {
+		data.Age = request.Age
+	}
+
+	prompt, err := renderPrompt(request.Body, data)
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Invalid template: " + err.Error()}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"prompt": prompt}, "")
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"log"
+	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/model"
+	"owlllovo/ginessential/realtime"
+	"owlllovo/ginessential/response"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+	"golang.org/x/net/websocket"
+	"gorm.io/gorm"
+)
+
+// Connect 把请求升级为 WebSocket，推送新消息、对方正在输入和送达回执
+//
+// 客户端可以发送 typing（chat_id）、ack（chat_id, message_id）和 ping 事件
+func (c *ChatController) Connect(ctx *gin.Context) {
+	user, _ := ctx.Get("user")
+	userID := user.(model.User).ID
+	claims, _ := ctx.Get("claims")
+
+	websocket.Handler(func(conn *websocket.Conn) {
+		done := make(chan struct{})
+		defer close(done)
+		go watchRevocation(conn, userID, claims.(*common.Claims), done)
+
+		realtime.DefaultHub.Serve(conn, userID, func(client *realtime.Client, event realtime.Event) {
+			c.handleRealtimeEvent(client, event)
+		})
+	}).ServeHTTP(ctx.Writer, ctx.Request)
+}
+
+// WebSocketTicket 为当前登录会话签发一次性的连接凭证，浏览器用 /ws/chat?ticket= 建立连接
+func (c *ChatController) WebSocketTicket(ctx *gin.Context) {
+	claims, _ := ctx.Get("claims")
+	ticket, expiresAt, err := common.IssueWebSocketTicket(claims.(*common.Claims))
+	if err != nil {
+		log.Printf("Failed to issue websocket ticket: %v", err)
+		response.Fail(ctx, gin.H{"error": "Failed to issue websocket ticket"}, "")
+		return
+	}
+	response.Success(ctx, gin.H{"ticket": ticket, "expires_at": expiresAt}, "")
+}
+
+// watchRevocation 只在握手时认证不够：定期检查连接所属的会话，退出登录、"退出所有设备"
+// 或用户被删除后关闭连接
+func watchRevocation(conn *websocket.Conn, userID uint, claims *common.Claims, done <-chan struct{}) {
+	interval := viper.GetDuration("realtime.revalidateInterval")
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			var user model.User
+			if err := common.GetDB().First(&user, userID).Error; err == nil && !common.IsTokenRevoked(claims, user) {
+				continue
+			}
+			log.Printf("Closing websocket of user %d: session revoked", userID)
+			conn.Close()
+			return
+		}
+	}
+}
+
+func (c *ChatController) handleRealtimeEvent(client *realtime.Client, event realtime.Event) {
+	switch event.Type {
+	case realtime.EventTyping, realtime.EventAck:
+	default:
+		client.Send(realtime.Event{Type: realtime.EventError, Error: "Unknown event type"})
+		return
+	}
+
+	chat, ok := c.participantChat(event.ChatID, client.UserID)
+	if !ok {
+		client.Send(realtime.Event{Type: realtime.EventError, ChatID: event.ChatID, Error: "Chat not found"})
+		return
+	}
+	other := otherParticipant(chat, client.UserID)
+
+	switch event.Type {
+	case realtime.EventTyping:
+		realtime.DefaultHub.SendToUsers(realtime.Event{
+			Type:   realtime.EventTyping,
+			ChatID: chat.ID.String(),
+			UserID: client.UserID,
+		}, other)
+	case realtime.EventAck:
+		// 只把回执转发给消息的发送者
+		var message model.Message
+		if err := c.DB.Where("id = ? AND chat_id = ?", event.MessageID, chat.ID).First(&message).Error; err != nil {
+			client.Send(realtime.Event{Type: realtime.EventError, ChatID: event.ChatID, Error: "Message not found"})
+			return
+		}
+		if message.SenderID == client.UserID {
+			return
+		}
+		realtime.DefaultHub.SendToUsers(realtime.Event{
+			Type:      realtime.EventDelivered,
+			ChatID:    chat.ID.String(),
+			MessageID: message.ID.String(),
+			UserID:    client.UserID,
+		}, message.SenderID)
+	}
+}
+
+// participantChat 查找 userID 参与的 Chat
+func (c *ChatController) participantChat(chatID string, userID uint) (model.Chat, bool) {
+	var chat model.Chat
+	id, err := uuid.FromString(chatID)
+	if err != nil {
+		return chat, false
+	}
+	err = c.DB.Where("id = ? AND (sender_id = ? OR receiver_id = ?)", id, userID, userID).First(&chat).Error
+	return chat, err == nil
+}
+
+func otherParticipant(chat model.Chat, userID uint) uint {
+	if chat.SenderID == userID {
+		return chat.ReceiverID
+	}
+	return chat.SenderID
+}
+
+// broadcastMessage 把新保存的消息推送给 Chat 的双方
+func broadcastMessage(db *gorm.DB, message model.Message) {
+	var chat model.Chat
+	if err := db.Where("id = ?", message.ChatID).First(&chat).Error; err != nil {
+		log.Printf("Failed to load chat %s for broadcast: %v", message.ChatID, err)
+		return
+	}
+	realtime.DefaultHub.SendToUsers(realtime.Event{
+		Type:    realtime.EventMessage,
+		ChatID:  chat.ID.String(),
+		Message: message,
+	}, chat.SenderID, chat.ReceiverID)
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"owlllovo/ginessential/model"
+	"owlllovo/ginessential/repository"
+	"owlllovo/ginessential/response"
+	"owlllovo/ginessential/vo"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IRoleController interface {
+	RestController
+	ListAll(ctx *gin.Context)
+	ListPermissions(ctx *gin.Context)
+}
+
+type RoleController struct {
+	Repository repository.RoleRepository
+}
+
+func NewRoleController() IRoleController {
+	return RoleController{Repository: repository.NewRoleRepository()}
+}
+
+func (r RoleController) Create(ctx *gin.Context) {
+	var requestRole vo.RoleRequest
+	if err := ctx.ShouldBind(&requestRole); err != nil {
+		response.Fail(ctx, gin.H{"error": "Data Error, Please Fill Role Name"}, "")
+		return
+	}
+
+	role, err := r.Repository.Create(requestRole.Name, requestRole.Description, requestRole.Permissions)
+	if unknownPermissions(ctx, err) {
+		return
+	}
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Role already exists"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"role": role}, "Create Success")
+}
+
+func (r RoleController) Update(ctx *gin.Context) {
+	var requestRole vo.RoleRequest
+	if err := ctx.ShouldBind(&requestRole); err != nil {
+		response.Fail(ctx, gin.H{"error": "Data Error, Please Fill Role Name"}, "")
+		return
+	}
+
+	roleId, _ := strconv.Atoi(ctx.Params.ByName("id"))
+	updateRole, err := r.Repository.SelectById(roleId)
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Role does not exist"}, "")
+		return
+	}
+	if updateRole.Name == model.RoleAdmin {
+		// Admin 角色启动时会被重新授予全部权限，不允许修改
+		response.Fail(ctx, gin.H{"error": "Admin role cannot be modified"}, "")
+		return
+	}
+	if isBuiltinRole(updateRole.Name) && requestRole.Name != updateRole.Name {
+		// 代码中按名称引用内置角色，例如注册时的 User 和 AI 账号的 AI
+		response.Fail(ctx, gin.H{"error": "Built-in roles cannot be renamed"}, "")
+		return
+	}
+
+	role, err := r.Repository.Update(*updateRole, requestRole.Name, requestRole.Description, requestRole.Permissions)
+	if unknownPermissions(ctx, err) {
+		return
+	}
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Update Failed"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"role": role}, "Update Success")
+}
+
+func (r RoleController) Show(ctx *gin.Context) {
+	roleId, _ := strconv.Atoi(ctx.Params.ByName("id"))
+
+	role, err := r.Repository.SelectById(roleId)
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Role does not exist"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"role": role}, "")
+}
+
+func (r RoleController) Delete(ctx *gin.Context) {
+	roleId, _ := strconv.Atoi(ctx.Params.ByName("id"))
+
+	role, err := r.Repository.SelectById(roleId)
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Role does not exist"}, "")
+		return
+	}
+	if isBuiltinRole(role.Name) {
+		response.Fail(ctx, gin.H{"error": "Built-in roles cannot be deleted"}, "")
+		return
+	}
+	if count, err := r.Repository.CountUsers(role.Name); err != nil || count > 0 {
+		response.Fail(ctx, gin.H{"error": "Role is still assigned to users"}, "")
+		return
+	}
+
+	if err := r.Repository.DeleteById(roleId); err != nil {
+		response.Fail(ctx, gin.H{"error": "Delete Failed"}, "")
+		return
+	}
+
+	response.Success(ctx, nil, "")
+}
+
+// isBuiltinRole 判断是否为启动时创建、代码中按名称引用的角色
+func isBuiltinRole(name string) bool {
+	switch name {
+	case model.RoleAdmin, model.RoleModerator, model.RoleUser, model.RoleAI:
+		return true
+	}
+	return false
+}
+
+// unknownPermissions 在请求包含不存在的权限时返回 422
+func unknownPermissions(ctx *gin.Context, err error) bool {
+	var unknown repository.UnknownPermissionsError
+	if !errors.As(err, &unknown) {
+		return false
+	}
+	response.Response(ctx, http.StatusUnprocessableEntity, 422, gin.H{"unknown": unknown.Names}, "Permission does not exist")
+	return true
+}
+
+func (r RoleController) ListAll(ctx *gin.Context) {
+	roles, err := r.Repository.ListAll()
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to retrieve roles"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"roles": roles}, "")
+}
+
+func (r RoleController) ListPermissions(ctx *gin.Context) {
+	permissions, err := r.Repository.ListPermissions()
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to retrieve permissions"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"permissions": permissions}, "")
+}
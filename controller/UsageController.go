@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/model"
+	"owlllovo/ginessential/response"
+	"owlllovo/ginessential/vo"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type IUsageController interface {
+	Report(ctx *gin.Context)
+	Mine(ctx *gin.Context)
+	ShowQuota(ctx *gin.Context)
+	SetQuota(ctx *gin.Context)
+	DeleteQuota(ctx *gin.Context)
+}
+
+// UsageController 提供 AI 用量报表和限额管理
+type UsageController struct {
+	DB *gorm.DB
+}
+
+func NewUsageController() IUsageController {
+	db := common.GetDB()
+	db.AutoMigrate(&model.AIUsage{}, &model.AIQuota{})
+	return UsageController{DB: db}
+}
+
+// usageGroups 是报表可以使用的分组方式及对应的 SQL 表达式
+var usageGroups = map[string]string{
+	"user":     "user_id",
+	"provider": "provider",
+	"model":    "model",
+	"purpose":  "purpose",
+	"day":      "DATE(created_at)",
+}
+
+// usageRow 是报表中的一行
+type usageRow struct {
+	Key              string  `json:"key"`
+	Calls            int64   `json:"calls"`
+	Failures         int64   `json:"failures"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms"`
+}
+
+// usageRange 解析 ?from= 和 ?to=（YYYY-MM-DD），默认为本月
+func usageRange(ctx *gin.Context) (time.Time, time.Time, bool) {
+	now := time.Now()
+	from, to := startOfMonth(now), startOfDay(now).AddDate(0, 0, 1)
+	if value := ctx.Query("from"); value != "" {
+		t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return from, to, false
+		}
+		from = t
+	}
+	if value := ctx.Query("to"); value != "" {
+		t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return from, to, false
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+	return from, to, from.Before(to)
+}
+
+// Report 按 ?group=user|provider|model|purpose|day 汇总时间范围内的调用次数、token 和费用
+func (u UsageController) Report(ctx *gin.Context) {
+	group := ctx.DefaultQuery("group", "user")
+	column, ok := usageGroups[group]
+	if !ok {
+		response.Fail(ctx, gin.H{"error": "group must be one of user, provider, model, purpose, day"}, "")
+		return
+	}
+	from, to, ok := usageRange(ctx)
+	if !ok {
+		response.Fail(ctx, gin.H{"error": "Invalid date range, use YYYY-MM-DD"}, "")
+		return
+	}
+
+	query := u.DB.Model(&model.AIUsage{}).Where("created_at >= ? AND created_at < ?", from, to)
+	if purpose := ctx.Query("purpose"); purpose != "" {
+		query = query.Where("purpose = ?", purpose)
+	}
+
+	var rows []usageRow
+	if err := query.Select("CAST(" + column + " AS CHAR) AS `key`, COUNT(*) AS calls, " +
+		"SUM(CASE WHEN error <> '' THEN 1 ELSE 0 END) AS failures, " +
+		"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(completion_tokens), 0) AS completion_tokens, " +
+		"COALESCE(SUM(cost), 0) AS cost, COALESCE(AVG(latency_ms), 0) AS avg_latency_ms").
+		Group(column).Order("cost DESC").Scan(&rows).Error; err != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to build usage report"}, "")
+		return
+	}
+
+	var total usageRow
+	for _, row := range rows {
+		total.Calls += row.Calls
+		total.Failures += row.Failures
+		total.PromptTokens += row.PromptTokens
+		total.CompletionTokens += row.CompletionTokens
+		total.Cost += row.Cost
+	}
+	total.Key = "total"
+
+	response.Success(ctx, gin.H{
+		"from":  from.Format("2006-01-02"),
+		"to":    to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"group": group,
+		"rows":  rows,
+		"total": total,
+	}, "")
+}
+
+// quotaStatus 返回用户的限额和已用次数
+func (u UsageController) quotaStatus(user model.User) (gin.H, error) {
+	now := time.Now()
+	daily, err := countAIUsage(u.DB, user.ID, startOfDay(now))
+	if err != nil {
+		return nil, err
+	}
+	monthly, err := countAIUsage(u.DB, user.ID, startOfMonth(now))
+	if err != nil {
+		return nil, err
+	}
+
+	var override int64
+	u.DB.Model(&model.AIQuota{}).Where("user_id = ?", user.ID).Count(&override)
+	return gin.H{
+		"user_id":  user.ID,
+		"limits":   quotaLimits(u.DB, user),
+		"override": override > 0,
+		"used":     gin.H{"daily": daily, "monthly": monthly},
+	}, nil
+}
+
+// Mine 返回当前用户的 AI 限额和已用次数
+func (u UsageController) Mine(ctx *gin.Context) {
+	user, _ := ctx.Get("user")
+	status, err := u.quotaStatus(user.(model.User))
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to retrieve AI usage"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"quota": status}, "")
+}
+
+func (u UsageController) findUser(ctx *gin.Context) (*model.User, bool) {
+	userId, _ := strconv.Atoi(ctx.Params.ByName("id"))
+	var user model.User
+	if err := u.DB.First(&user, userId).Error; err != nil {
+		response.Fail(ctx, gin.H{"error": "User does not exist"}, "")
+		return nil, false
+	}
+	return &user, true
+}
+
+func (u UsageController) ShowQuota(ctx *gin.Context) {
+	user, ok := u.findUser(ctx)
+	if !ok {
+		return
+	}
+	status, err := u.quotaStatus(*user)
+	if err != nil {
+		response.Fail(ctx, gin.H{"error": "Failed to retrieve AI usage"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"quota": status}, "")
+}
+
+// SetQuota 为单个用户设置限额，覆盖其角色的默认限额
+func (u UsageController) SetQuota(ctx *gin.Context) {
+	var request vo.AIQuotaRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		response.Fail(ctx, gin.H{"error": "Data Error"}, "")
+		return
+	}
+	user, ok := u.findUser(ctx)
+	if !ok {
+		return
+	}
+
+	quota := model.AIQuota{UserID: user.ID, Daily: request.Daily, Monthly: request.Monthly}
+	if err := u.DB.Save(&quota).Error; err != nil {
+		response.Fail(ctx, gin.H{"error": "Update Failed"}, "")
+		return
+	}
+
+	response.Success(ctx, gin.H{"quota": quota}, "Update Success")
+}
+
+// DeleteQuota 删除用户的单独限额，恢复为角色的默认限额
+func (u UsageController) DeleteQuota(ctx *gin.Context) {
+	user, ok := u.findUser(ctx)
+	if !ok {
+		return
+	}
+	if err := u.DB.Delete(&model.AIQuota{}, user.ID).Error; err != nil {
+		response.Fail(ctx, gin.H{"error": "Delete Failed"}, "")
+		return
+	}
+
+	response.Success(ctx, nil, "")
+}
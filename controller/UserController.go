@@ -10,7 +10,9 @@ import (
 	"owlllovo/ginessential/model"
 	"owlllovo/ginessential/response"
 	"owlllovo/ginessential/util"
+	"owlllovo/ginessential/vo"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -25,7 +27,7 @@ func Register(ctx *gin.Context) {
 	// json.NewDecoder(ctx.Request.Body).Decode(&requestMap)
 
 	// get parameter via struct and gin-bind
-	var requestUser = model.User{}
+	var requestUser = vo.UserRequest{}
 	// json.NewDecoder(ctx.Request.Body).Decode(&requestUser)
 	ctx.Bind(&requestUser)
 
@@ -48,7 +50,16 @@ func Register(ctx *gin.Context) {
 		name = util.RandomString(10)
 	}
 	if len(role) == 0 {
-		role = "User"
+		role = model.RoleUser
+	} else if !canManageUsers(ctx) && !strings.EqualFold(role, model.RoleUser) {
+		// 公开注册只能创建普通用户，指定其它角色需要 user:manage 权限
+		response.Response(ctx, http.StatusForbidden, 403, nil, "Insufficient permissions")
+		return
+	}
+	role, ok := common.RoleExists(role)
+	if !ok {
+		response.Response(ctx, http.StatusUnprocessableEntity, 422, nil, "Role does not exist")
+		return
 	}
 
 	log.Println(name, telephone, password, role)
@@ -77,17 +88,15 @@ func Register(ctx *gin.Context) {
 
 	// send token
 
-	token, err := common.ReleaseToken(newUser)
+	tokens, err := common.IssueTokens(newUser)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "System Error"})
 		log.Printf("token generate error: %v", err)
 		return
 	}
-	var user model.User
-	DB.Where("telephone = ?", telephone).First(&user)
 
 	// return result
-	response.Success(ctx, gin.H{"token": token, "userid": user.ID}, "Register Success")
+	response.Success(ctx, tokenResponse(tokens, newUser.ID), "Register Success")
 }
 
 func Login(ctx *gin.Context) {
@@ -95,7 +104,7 @@ func Login(ctx *gin.Context) {
 	DB := common.GetDB()
 
 	// get parameter via struct and gin-bind
-	var requestUser = model.User{}
+	var requestUser = vo.UserRequest{}
 	// json.NewDecoder(ctx.Request.Body).Decode(&requestUser)
 	ctx.Bind(&requestUser)
 
@@ -132,7 +141,7 @@ func Login(ctx *gin.Context) {
 
 	// send token
 
-	token, err := common.ReleaseToken(user)
+	tokens, err := common.IssueTokens(user)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "System Error"})
 		log.Printf("token generate error: %v", err)
@@ -140,7 +149,106 @@ func Login(ctx *gin.Context) {
 	}
 
 	// return result
-	response.Success(ctx, gin.H{"token": token, "userid": user.ID}, "Login Success")
+	response.Success(ctx, tokenResponse(tokens, user.ID), "Login Success")
+}
+
+// JWKS 公开非对称签名密钥的公钥，供其它内部服务校验我们签发的 token
+func JWKS(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"keys": common.JWKS()})
+}
+
+func tokenResponse(tokens *common.TokenPair, userId uint) gin.H {
+	return gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"userid":        userId,
+	}
+}
+
+// Refresh 用 refresh token 换取新的 access token，旧的 refresh token 随即失效
+func Refresh(ctx *gin.Context) {
+	var request vo.RefreshTokenRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		response.Response(ctx, http.StatusBadRequest, 400, nil, "Refresh token is required")
+		return
+	}
+
+	tokens, user, err := common.RefreshTokens(request.RefreshToken)
+	if err != nil {
+		if errors.Is(err, common.ErrInvalidRefreshToken) || errors.Is(err, common.ErrRefreshTokenReused) {
+			response.Response(ctx, http.StatusUnauthorized, 401, nil, err.Error())
+			return
+		}
+		log.Printf("token refresh error: %v", err)
+		response.Response(ctx, http.StatusInternalServerError, 500, nil, "System Error")
+		return
+	}
+
+	response.Success(ctx, tokenResponse(tokens, user.ID), "Refresh Success")
+}
+
+// Logout 吊销当前 access token 以及所属会话的 refresh token
+func Logout(ctx *gin.Context) {
+	user, _ := ctx.Get("user")
+	claims, _ := ctx.Get("claims")
+
+	var request vo.LogoutRequest
+	ctx.ShouldBindJSON(&request)
+
+	if err := common.RevokeAccessToken(claims.(*common.Claims)); err != nil {
+		log.Printf("logout error: %v", err)
+		response.Response(ctx, http.StatusInternalServerError, 500, nil, "System Error")
+		return
+	}
+
+	// 旧版本签发的 token 没有 sid，只能通过 refresh token 找到会话
+	sessionId := claims.(*common.Claims).SessionId
+	if sessionId != "" {
+		if err := common.RevokeSession(sessionId); err != nil {
+			log.Printf("logout error: %v", err)
+			response.Response(ctx, http.StatusInternalServerError, 500, nil, "System Error")
+			return
+		}
+	} else if request.RefreshToken != "" {
+		common.RevokeSessionByRefreshToken(request.RefreshToken, user.(model.User).ID)
+	}
+
+	response.Success(ctx, nil, "Logout Success")
+}
+
+// LogoutAll 让当前用户在所有设备上退出登录
+func LogoutAll(ctx *gin.Context) {
+	user, _ := ctx.Get("user")
+
+	if err := common.RevokeAllSessions(user.(model.User).ID); err != nil {
+		log.Printf("logout all error: %v", err)
+		response.Response(ctx, http.StatusInternalServerError, 500, nil, "System Error")
+		return
+	}
+
+	response.Success(ctx, nil, "Logged out from all devices")
+}
+
+// LogoutUser 供管理员强制某个用户在所有设备上退出登录
+func LogoutUser(ctx *gin.Context) {
+	DB := common.GetDB()
+	userId := ctx.Param("id")
+
+	var user model.User
+	DB.First(&user, userId)
+	if user.ID == 0 {
+		response.Response(ctx, http.StatusNotFound, 404, nil, "User not found")
+		return
+	}
+
+	if err := common.RevokeAllSessions(user.ID); err != nil {
+		log.Printf("logout user error: %v", err)
+		response.Response(ctx, http.StatusInternalServerError, 500, nil, "System Error")
+		return
+	}
+
+	response.Success(ctx, nil, "User logged out from all devices")
 }
 
 func Info(ctx *gin.Context) {
@@ -149,6 +257,12 @@ func Info(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"code": 200, "data": gin.H{"user": dto.ToUserDto(user.(model.User))}})
 }
 
+// canManageUsers 判断当前请求是否来自拥有 user:manage 权限的已登录用户
+func canManageUsers(ctx *gin.Context) bool {
+	user, exists := ctx.Get("user")
+	return exists && common.HasPermission(user.(model.User).Role, model.PermUserManage)
+}
+
 func isTelephoneExist(db *gorm.DB, telephone string) bool {
 	var user model.User
 	db.Where("telephone = ?", telephone).First(&user)
@@ -164,7 +278,7 @@ func UpdateUser(ctx *gin.Context) {
 		return
 	}
 
-	var requestUser model.User
+	var requestUser vo.UserRequest
 	ctx.Bind(&requestUser)
 
 	// 省略电话号码和其他字段的验证...
@@ -173,8 +287,14 @@ func UpdateUser(ctx *gin.Context) {
 		requestUser.Name = util.RandomString(10)
 	}
 	if len(requestUser.Role) == 0 {
-		requestUser.Role = "user"
+		requestUser.Role = model.RoleUser
+	}
+	role, ok := common.RoleExists(requestUser.Role)
+	if !ok {
+		response.Response(ctx, http.StatusUnprocessableEntity, 422, nil, "Role does not exist")
+		return
 	}
+	requestUser.Role = role
 
 	if isNewTelephoneExist(DB, requestUser.Telephone, uint(userIdInt)) {
 		response.Response(ctx, http.StatusUnprocessableEntity, 422, nil, "Telephone already in use by another user")
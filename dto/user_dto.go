@@ -1,17 +1,22 @@
 package dto
 
-import "owlllovo/ginessential/model"
+import (
+	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/model"
+)
 
 type UserDto struct {
-	Name      string `json:"name"`
-	Telephone string `json:"telephone"`
-	Role      string `json:"role"`
+	Name        string   `json:"name"`
+	Telephone   string   `json:"telephone"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
 }
 
 func ToUserDto(user model.User) UserDto {
 	return UserDto{
-		Name:      user.Name,
-		Telephone: user.Telephone,
-		Role:      user.Role,
+		Name:        user.Name,
+		Telephone:   user.Telephone,
+		Role:        user.Role,
+		Permissions: common.RolePermissions(user.Role),
 	}
 }
@@ -0,0 +1,92 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"math/bits"
+	"sort"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+const phashSize = 32
+
+// PHash 计算图片的感知哈希：缩小到 32×32 灰度图后做二维 DCT，
+// 取左上角 8×8 的低频系数（不含直流分量），大于中位数的位记为 1。
+// 缩放、重新压缩、轻微调色后哈希基本不变，用 Distance 比较两张图片是否相似
+func PHash(img image.Image) uint64 {
+	gray := image.NewGray(image.Rect(0, 0, phashSize, phashSize))
+	xdraw.ApproxBiLinear.Scale(gray, gray.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var pixels [phashSize][phashSize]float64
+	for y := 0; y < phashSize; y++ {
+		for x := 0; x < phashSize; x++ {
+			pixels[y][x] = float64(gray.GrayAt(x, y).Y)
+		}
+	}
+
+	coeffs := make([]float64, 0, 64)
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			coeffs = append(coeffs, dct(&pixels, u, v))
+		}
+	}
+
+	sorted := append([]float64(nil), coeffs[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for i, c := range coeffs[1:] {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// dct 返回二维 DCT-II 在 (u, v) 处的系数，省略了不影响比较的归一化常数
+func dct(pixels *[phashSize][phashSize]float64, u, v int) float64 {
+	sum := 0.0
+	for y := 0; y < phashSize; y++ {
+		cy := math.Cos(float64((2*y+1)*v) * math.Pi / (2 * phashSize))
+		for x := 0; x < phashSize; x++ {
+			sum += pixels[y][x] * cy * math.Cos(float64((2*x+1)*u)*math.Pi/(2*phashSize))
+		}
+	}
+	return sum
+}
+
+// Distance 返回两个感知哈希不同的位数，0 表示几乎相同，超过 20 一般是不同的图片
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// PHashBands 是感知哈希切分的段数，每段 16 位
+const PHashBands = 4
+
+// Bands 把感知哈希切成四段 16 位。两个哈希的距离不超过 d 时，至少有一段的距离不超过 d/4（抽屉原理），
+// 所以可以先按分段在索引中筛出候选，再精确计算距离
+func Bands(hash uint64) [PHashBands]uint16 {
+	var bands [PHashBands]uint16
+	for i := range bands {
+		bands[i] = uint16(hash >> (16 * uint(i)))
+	}
+	return bands
+}
+
+// BandNeighbors 返回与 band 距离不超过 radius 的所有 16 位值，包括 band 本身
+func BandNeighbors(band uint16, radius int) []uint16 {
+	neighbors := []uint16{band}
+	var flip func(value uint16, from, left int)
+	flip = func(value uint16, from, left int) {
+		for bit := from; bit < 16 && left > 0; bit++ {
+			next := value ^ 1<<uint(bit)
+			neighbors = append(neighbors, next)
+			flip(next, bit+1, left-1)
+		}
+	}
+	flip(band, 0, radius)
+	return neighbors
+}
@@ -0,0 +1,166 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// drawing 生成一张确定的测试图片：斜向渐变上叠加一个圆
+func drawing(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{uint8(255 * x / w), uint8(255 * y / h), 128, 255}
+			dx, dy := x-w/3, y-h/3
+			if dx*dx+dy*dy < w*w/25 {
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// stripes 生成与 drawing 完全不同的竖条纹图片
+func stripes(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(0)
+			if (x/(w/8))%2 == 0 {
+				v = 255
+			}
+			img.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestPHash(t *testing.T) {
+	original := drawing(400, 300)
+
+	scaled := image.NewRGBA(image.Rect(0, 0, 160, 120))
+	xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), original, original.Bounds(), xdraw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, original, &jpeg.Options{Quality: 40}); err != nil {
+		t.Fatal(err)
+	}
+	recompressed, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		img     image.Image
+		minDist int
+		maxDist int
+	}{
+		{"identical", drawing(400, 300), 0, 0},
+		{"scaled down", scaled, 0, 4},
+		{"recompressed", recompressed, 0, 4},
+		{"different drawing", stripes(400, 300), 16, 64},
+	}
+
+	hash := PHash(original)
+	if hash == 0 {
+		t.Fatal("PHash of a non-uniform image is 0")
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Distance(hash, PHash(tt.img))
+			if d < tt.minDist || d > tt.maxDist {
+				t.Errorf("distance = %d, want between %d and %d", d, tt.minDist, tt.maxDist)
+			}
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0xffffffffffffffff, 0xffffffffffffffff, 0},
+		{0, 1, 1},
+		{0, 0xffffffffffffffff, 64},
+		{0xf0f0f0f0f0f0f0f0, 0x0f0f0f0f0f0f0f0f, 64},
+		{0b1011, 0b0110, 3},
+	}
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("Distance(%#x, %#x) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := Distance(tt.b, tt.a); got != tt.want {
+			t.Errorf("Distance(%#x, %#x) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestBands(t *testing.T) {
+	got := Bands(0x1111222233334444)
+	want := [PHashBands]uint16{0x4444, 0x3333, 0x2222, 0x1111}
+	if got != want {
+		t.Errorf("Bands = %#x, want %#x", got, want)
+	}
+}
+
+func TestBandNeighbors(t *testing.T) {
+	tests := []struct {
+		radius int
+		want   int
+	}{
+		{0, 1},
+		{1, 1 + 16},
+		{2, 1 + 16 + 120},
+		{3, 1 + 16 + 120 + 560},
+	}
+	const band = 0xabcd
+	for _, tt := range tests {
+		neighbors := BandNeighbors(band, tt.radius)
+		if len(neighbors) != tt.want {
+			t.Errorf("BandNeighbors(radius %d) returned %d values, want %d", tt.radius, len(neighbors), tt.want)
+		}
+		seen := make(map[uint16]bool)
+		for _, n := range neighbors {
+			if seen[n] {
+				t.Errorf("BandNeighbors(radius %d) returned %#x twice", tt.radius, n)
+			}
+			seen[n] = true
+			if d := Distance(uint64(n), band); d > tt.radius {
+				t.Errorf("BandNeighbors(radius %d) returned %#x at distance %d", tt.radius, n, d)
+			}
+		}
+	}
+}
+
+// 两个哈希距离不超过 d 时，至少有一段的距离不超过 d/4，按分段筛选不会漏掉近似图片
+func TestBandsPigeonhole(t *testing.T) {
+	a := uint64(0x0123456789abcdef)
+	for d := 0; d <= 15; d++ {
+		// 把 d 个不同的位尽量平均地分到四段里，这是最难命中的情况
+		b := a
+		for i := 0; i < d; i++ {
+			b ^= 1 << uint((i%PHashBands)*16+i/PHashBands)
+		}
+		if Distance(a, b) != d {
+			t.Fatalf("setup: distance = %d, want %d", Distance(a, b), d)
+		}
+		found := false
+		for i, band := range Bands(b) {
+			if Distance(uint64(band), uint64(Bands(a)[i])) <= d/PHashBands {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("distance %d: no band within %d", d, d/PHashBands)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"owlllovo/ginessential/storage"
+	"strings"
+
+	"github.com/spf13/viper"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	Thumb    = "thumb"
+	Medium   = "medium"
+	Original = "original"
+)
+
+// Renditions 是生成的尺寸，顺序从小到大
+var Renditions = []string{Thumb, Medium, Original}
+
+// Options 是 image 下的配置，尺寸都是最长边的像素数
+type Options struct {
+	ThumbSize   int
+	MediumSize  int
+	MaxSize     int
+	JPEGQuality int
+	Limits      Limits
+}
+
+// LoadOptions 读取 image.* 配置，未配置的项使用默认值
+func LoadOptions() Options {
+	opts := Options{
+		ThumbSize:   viper.GetInt("image.thumbSize"),
+		MediumSize:  viper.GetInt("image.mediumSize"),
+		MaxSize:     viper.GetInt("image.maxSize"),
+		JPEGQuality: viper.GetInt("image.jpegQuality"),
+		Limits:      LoadLimits(),
+	}
+	if opts.ThumbSize <= 0 {
+		opts.ThumbSize = 320
+	}
+	if opts.MediumSize <= 0 {
+		opts.MediumSize = 1080
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 2048
+	}
+	if opts.JPEGQuality <= 0 || opts.JPEGQuality > 100 {
+		opts.JPEGQuality = 85
+	}
+	return opts
+}
+
+func (o Options) size(rendition string) int {
+	switch rendition {
+	case Thumb:
+		return o.ThumbSize
+	case Medium:
+		return o.MediumSize
+	default:
+		return o.MaxSize
+	}
+}
+
+// Decorator 在生成各个尺寸之前处理转正、缩小后的原图，例如加水印
+type Decorator func(img image.Image) (image.Image, error)
+
+// Result 是处理后的各尺寸 JPEG 数据，Source 是上传文件校验时得到的信息
+type Result struct {
+	Source *Info
+	// PHash 是转正、缩小后、加水印前的感知哈希，用于查找重复上传
+	PHash  uint64
+	Format string
+	Width  int
+	Height int
+	Files  map[string][]byte
+}
+
+// Process 先用 Validate 检查上传的文件，再解码 JPEG / PNG / GIF / WebP（GIF 只取第一帧），
+// 按 EXIF 转正，限制最大尺寸，调用 decorate 后统一输出为 thumb / medium / original 三个 JPEG
+func Process(data []byte, opts Options, decorate Decorator) (*Result, error) {
+	source, err := Validate(data, opts.Limits)
+	if err != nil {
+		return nil, err
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+
+	original := image.Image(flatten(fit(img, opts.MaxSize)))
+	phash := PHash(original)
+	if decorate != nil {
+		if original, err = decorate(original); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{
+		Source: source,
+		PHash:  phash,
+		Format: format,
+		Width:  original.Bounds().Dx(),
+		Height: original.Bounds().Dy(),
+		Files:  make(map[string][]byte, len(Renditions)),
+	}
+	for _, rendition := range Renditions {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, fit(original, opts.size(rendition)), &jpeg.Options{Quality: opts.JPEGQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", rendition, err)
+		}
+		result.Files[rendition] = buf.Bytes()
+	}
+	return result, nil
+}
+
+// FileName 返回某个尺寸的文件名，base 是上传时生成的 uuid；
+// 扩展名取自输出格式（统一为 JPEG），不使用客户端上传的文件名
+func FileName(base, rendition string) string {
+	return base + "_" + rendition + ".jpg"
+}
+
+// RenditionNames 根据帖子保存的 HeadImg（原图文件名）推算各个尺寸的文件名；
+// 旧的上传没有多个尺寸，全部返回原文件
+func RenditionNames(headImg string) map[string]string {
+	names := make(map[string]string, len(Renditions))
+	base, ok := strings.CutSuffix(headImg, "_"+Original+".jpg")
+	for _, rendition := range Renditions {
+		if ok {
+			names[rendition] = FileName(base, rendition)
+		} else {
+			names[rendition] = headImg
+		}
+	}
+	return names
+}
+
+// URLs 返回各个尺寸的访问地址，headImg 为空时返回 nil
+func URLs(headImg string) map[string]string {
+	if headImg == "" {
+		return nil
+	}
+	urls := RenditionNames(headImg)
+	for rendition, name := range urls {
+		urls[rendition] = storage.Default().URL(name)
+	}
+	return urls
+}
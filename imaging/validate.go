@@ -0,0 +1,122 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	// ErrFileTooLarge 表示文件超过 image.maxBytes
+	ErrFileTooLarge = errors.New("image file is too large")
+	// ErrUnsupportedType 表示文件内容不是允许的图片类型
+	ErrUnsupportedType = errors.New("unsupported image type")
+	// ErrDimensionsTooLarge 表示图片宽高或总像素数超过限制
+	ErrDimensionsTooLarge = errors.New("image dimensions are too large")
+	// ErrInvalidImage 表示文件头无法解析，或与嗅探到的类型不一致
+	ErrInvalidImage = errors.New("invalid image")
+)
+
+// formats 是允许上传的格式，key 是 image.DecodeConfig 返回的格式名
+var formats = map[string]struct {
+	MIME string
+	Ext  string
+}{
+	"jpeg": {"image/jpeg", ".jpg"},
+	"png":  {"image/png", ".png"},
+	"gif":  {"image/gif", ".gif"},
+	"webp": {"image/webp", ".webp"},
+}
+
+// Limits 是上传图片的限制，在完整解码之前检查
+type Limits struct {
+	MaxBytes     int64
+	MaxDimension int
+	MaxPixels    int
+	AllowedTypes []string
+}
+
+// LoadLimits 读取 image.maxBytes / maxDimension / maxPixels / allowedTypes 配置
+func LoadLimits() Limits {
+	limits := Limits{
+		MaxBytes:     viper.GetInt64("image.maxBytes"),
+		MaxDimension: viper.GetInt("image.maxDimension"),
+		MaxPixels:    viper.GetInt("image.maxPixels"),
+		AllowedTypes: viper.GetStringSlice("image.allowedTypes"),
+	}
+	if limits.MaxBytes <= 0 {
+		limits.MaxBytes = 10 << 20
+	}
+	if limits.MaxDimension <= 0 {
+		limits.MaxDimension = 10000
+	}
+	if limits.MaxPixels <= 0 {
+		limits.MaxPixels = 40_000_000
+	}
+	if len(limits.AllowedTypes) == 0 {
+		limits.AllowedTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+	}
+	return limits
+}
+
+func (l Limits) allowed(mime string) bool {
+	for _, t := range l.AllowedTypes {
+		if strings.EqualFold(t, mime) {
+			return true
+		}
+	}
+	return false
+}
+
+// Info 是校验通过的图片信息，MIME 和 Ext 由文件内容决定，与客户端给出的文件名无关
+type Info struct {
+	Format string
+	MIME   string
+	Ext    string
+	Width  int
+	Height int
+}
+
+// Validate 检查文件大小，按内容嗅探 MIME 类型，再用 image.DecodeConfig 只读文件头检查宽高，
+// 防止解压炸弹在完整解码时占满内存
+func Validate(data []byte, limits Limits) (*Info, error) {
+	if int64(len(data)) > limits.MaxBytes {
+		return nil, fmt.Errorf("%w: %d bytes, limit is %d", ErrFileTooLarge, len(data), limits.MaxBytes)
+	}
+
+	mime := http.DetectContentType(data)
+	if !limits.allowed(mime) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, mime)
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	detected, ok := formats[format]
+	if !ok || detected.MIME != mime {
+		return nil, fmt.Errorf("%w: content looks like %s but decodes as %s", ErrInvalidImage, mime, format)
+	}
+
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, fmt.Errorf("%w: %dx%d", ErrInvalidImage, config.Width, config.Height)
+	}
+	if config.Width > limits.MaxDimension || config.Height > limits.MaxDimension ||
+		int64(config.Width)*int64(config.Height) > int64(limits.MaxPixels) {
+		return nil, fmt.Errorf("%w: %dx%d, limit is %d per side and %d pixels",
+			ErrDimensionsTooLarge, config.Width, config.Height, limits.MaxDimension, limits.MaxPixels)
+	}
+
+	return &Info{
+		Format: format,
+		MIME:   mime,
+		Ext:    detected.Ext,
+		Width:  config.Width,
+		Height: config.Height,
+	}, nil
+}
@@ -0,0 +1,95 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodeImage(t *testing.T, format string, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pngBomb 返回一个很小的 PNG，但文件头声明的宽高是 w×h
+func pngBomb(t *testing.T, w, h uint32) []byte {
+	t.Helper()
+	data := encodeImage(t, "png", 1, 1)
+	// 8 字节签名之后是 IHDR：长度(4) 类型(4) 宽(4) 高(4) ...，CRC 覆盖类型和数据
+	binary.BigEndian.PutUint32(data[16:20], w)
+	binary.BigEndian.PutUint32(data[20:24], h)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestValidate(t *testing.T) {
+	limits := Limits{
+		MaxBytes:     1 << 20,
+		MaxDimension: 4000,
+		MaxPixels:    8_000_000,
+		AllowedTypes: []string{"image/jpeg", "image/png", "image/gif"},
+	}
+	pngData := encodeImage(t, "png", 40, 30)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		limits  Limits
+		want    *Info
+		wantErr error
+	}{
+		{"png", pngData, limits, &Info{Format: "png", MIME: "image/png", Ext: ".png", Width: 40, Height: 30}, nil},
+		{"jpeg", encodeImage(t, "jpeg", 20, 10), limits, &Info{Format: "jpeg", MIME: "image/jpeg", Ext: ".jpg", Width: 20, Height: 10}, nil},
+		{"gif", encodeImage(t, "gif", 5, 5), limits, &Info{Format: "gif", MIME: "image/gif", Ext: ".gif", Width: 5, Height: 5}, nil},
+		{"file too large", pngData, Limits{MaxBytes: int64(len(pngData) - 1), MaxDimension: 4000, MaxPixels: 8_000_000, AllowedTypes: limits.AllowedTypes}, nil, ErrFileTooLarge},
+		{"size limit is inclusive", pngData, Limits{MaxBytes: int64(len(pngData)), MaxDimension: 4000, MaxPixels: 8_000_000, AllowedTypes: limits.AllowedTypes},
+			&Info{Format: "png", MIME: "image/png", Ext: ".png", Width: 40, Height: 30}, nil},
+		{"not an image", []byte("<html><body>hello</body></html>"), limits, nil, ErrUnsupportedType},
+		{"type not allowed", pngData, Limits{MaxBytes: 1 << 20, MaxDimension: 4000, MaxPixels: 8_000_000, AllowedTypes: []string{"image/jpeg"}}, nil, ErrUnsupportedType},
+		{"png signature without a png inside", append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...), limits, nil, ErrInvalidImage},
+		{"truncated jpeg", encodeImage(t, "jpeg", 20, 10)[:4], limits, nil, ErrInvalidImage},
+		{"zero width", pngBomb(t, 0, 10), limits, nil, ErrInvalidImage},
+		{"too wide", pngBomb(t, 4001, 10), limits, nil, ErrDimensionsTooLarge},
+		{"too tall", pngBomb(t, 10, 4001), limits, nil, ErrDimensionsTooLarge},
+		{"too many pixels", pngBomb(t, 4000, 2001), limits, nil, ErrDimensionsTooLarge},
+		{"decompression bomb", pngBomb(t, 60000, 60000), limits, nil, ErrDimensionsTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Validate(tt.data, tt.limits)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Validate = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate = %v", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("Validate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
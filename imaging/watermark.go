@@ -0,0 +1,351 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/fogleman/gg"
+	"github.com/spf13/viper"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+const (
+	PositionCenter      = "center"
+	PositionTopLeft     = "top-left"
+	PositionTopRight    = "top-right"
+	PositionBottomLeft  = "bottom-left"
+	PositionBottomRight = "bottom-right"
+	// PositionTile 把文字斜着铺满整张图片
+	PositionTile = "tile"
+)
+
+// WatermarkOptions 是 watermark 下的配置，比例类的值都相对于图片的短边
+type WatermarkOptions struct {
+	Enabled  bool
+	Text     string
+	Site     string
+	Position string
+	Opacity  float64
+	Color    string
+	// FontScale 是字号占图片短边的比例，MinFontSize 是最小字号
+	FontScale   float64
+	MinFontSize float64
+	Font        string
+	Margin      float64
+	// Logo 为空时不叠加图片，LogoScale 是 logo 宽度占图片宽度的比例
+	Logo         string
+	LogoPosition string
+	LogoScale    float64
+	LogoOpacity  float64
+}
+
+// WatermarkData 是水印文字模板中可以使用的变量
+type WatermarkData struct {
+	Site     string
+	Author   string
+	AuthorID uint
+	Date     string
+}
+
+// LoadWatermarkOptions 读取 watermark.* 配置，未配置的项使用默认值
+func LoadWatermarkOptions() WatermarkOptions {
+	opts := WatermarkOptions{
+		Enabled:      true,
+		Text:         viper.GetString("watermark.text"),
+		Site:         viper.GetString("watermark.site"),
+		Position:     viper.GetString("watermark.position"),
+		Opacity:      viper.GetFloat64("watermark.opacity"),
+		Color:        viper.GetString("watermark.color"),
+		FontScale:    viper.GetFloat64("watermark.fontScale"),
+		MinFontSize:  viper.GetFloat64("watermark.minFontSize"),
+		Font:         viper.GetString("watermark.font"),
+		Margin:       viper.GetFloat64("watermark.margin"),
+		Logo:         viper.GetString("watermark.logo"),
+		LogoPosition: viper.GetString("watermark.logoPosition"),
+		LogoScale:    viper.GetFloat64("watermark.logoScale"),
+		LogoOpacity:  viper.GetFloat64("watermark.logoOpacity"),
+	}
+	if viper.IsSet("watermark.enabled") {
+		opts.Enabled = viper.GetBool("watermark.enabled")
+	}
+	if opts.Text == "" {
+		opts.Text = "@{{.Author}}"
+	}
+	if opts.Position == "" {
+		opts.Position = PositionBottomRight
+	}
+	if opts.Opacity <= 0 || opts.Opacity > 1 {
+		opts.Opacity = 0.5
+	}
+	if opts.Color == "" {
+		opts.Color = "#ffffff"
+	}
+	if opts.FontScale <= 0 {
+		opts.FontScale = 0.04
+	}
+	if opts.MinFontSize <= 0 {
+		opts.MinFontSize = 12
+	}
+	if opts.Font == "" {
+		opts.Font = "assets/fonts/MS.ttf"
+	}
+	if opts.Margin <= 0 {
+		opts.Margin = 0.03
+	}
+	if opts.LogoPosition == "" {
+		opts.LogoPosition = PositionBottomLeft
+	}
+	if opts.LogoScale <= 0 || opts.LogoScale > 1 {
+		opts.LogoScale = 0.15
+	}
+	if opts.LogoOpacity <= 0 || opts.LogoOpacity > 1 {
+		opts.LogoOpacity = opts.Opacity
+	}
+	return opts
+}
+
+var (
+	fontMu    sync.Mutex
+	fontCache = map[string]*opentype.Font{}
+	logoCache = map[string]image.Image{}
+)
+
+// loadFont 读取并缓存字体；配置的字体不存在或无法解析时使用内置的 Go Regular 字体，它没有中文字形
+func loadFont(path string) (*opentype.Font, error) {
+	fontMu.Lock()
+	defer fontMu.Unlock()
+	if f, ok := fontCache[path]; ok {
+		return f, nil
+	}
+
+	var f *opentype.Font
+	data, err := os.ReadFile(path)
+	if err == nil {
+		f, err = opentype.Parse(data)
+	}
+	if err != nil {
+		log.Printf("Watermark font %s unavailable (%v), using embedded font", path, err)
+		if f, err = opentype.Parse(goregular.TTF); err != nil {
+			return nil, err
+		}
+	}
+	fontCache[path] = f
+	return f, nil
+}
+
+// loadLogo 读取并缓存 logo 图片
+func loadLogo(path string) (image.Image, error) {
+	fontMu.Lock()
+	defer fontMu.Unlock()
+	if logo, ok := logoCache[path]; ok {
+		return logo, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	logo, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+	logoCache[path] = logo
+	return logo, nil
+}
+
+// parseColor 解析 #rrggbb，格式不对时返回白色
+func parseColor(hex string) (float64, float64, float64) {
+	hex = strings.TrimPrefix(hex, "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return 1, 1, 1
+	}
+	return float64(v>>16&0xff) / 255, float64(v>>8&0xff) / 255, float64(v&0xff) / 255
+}
+
+// anchor 返回某个位置在图片上的坐标以及对齐方式
+func anchor(position string, w, h, margin float64) (x, y, ax, ay float64) {
+	switch position {
+	case PositionTopLeft:
+		return margin, margin, 0, 0
+	case PositionTopRight:
+		return w - margin, margin, 1, 0
+	case PositionBottomLeft:
+		return margin, h - margin, 0, 1
+	case PositionBottomRight:
+		return w - margin, h - margin, 1, 1
+	default:
+		return w / 2, h / 2, 0.5, 0.5
+	}
+}
+
+// RenderWatermarkText 用 data 渲染水印文字模板
+func RenderWatermarkText(text string, data WatermarkData) (string, error) {
+	tmpl, err := template.New("watermark").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// Watermark 返回按配置添加文字和 logo 水印的 Decorator，未开启时返回 nil。
+// 水印失败不会让上传失败：模板、字体或 logo 有问题时只跳过出问题的部分
+func Watermark(opts WatermarkOptions, data WatermarkData) Decorator {
+	if !opts.Enabled {
+		return nil
+	}
+	if data.Site == "" {
+		data.Site = opts.Site
+	}
+	if data.Date == "" {
+		data.Date = time.Now().Format("2006-01-02")
+	}
+	text, err := RenderWatermarkText(opts.Text, data)
+	if err != nil {
+		log.Printf("Invalid watermark template: %v", err)
+		text = ""
+	}
+
+	return func(img image.Image) (image.Image, error) {
+		dc := gg.NewContextForImage(img)
+		w, h := float64(dc.Width()), float64(dc.Height())
+		short := math.Min(w, h)
+		margin := opts.Margin * short
+
+		if opts.Logo != "" {
+			if err := drawLogo(dc, opts, margin); err != nil {
+				log.Printf("Failed to draw watermark logo: %v", err)
+			}
+		}
+		if text == "" {
+			return dc.Image(), nil
+		}
+
+		f, err := loadFont(opts.Font)
+		if err != nil {
+			log.Printf("Failed to load watermark font: %v", err)
+			return dc.Image(), nil
+		}
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{
+			Size:    math.Max(opts.MinFontSize, opts.FontScale*short),
+			DPI:     72,
+			Hinting: font.HintingFull,
+		})
+		if err != nil {
+			log.Printf("Failed to create watermark font face: %v", err)
+			return dc.Image(), nil
+		}
+		defer face.Close()
+		// 字体缺字时会画出方框，不如不画
+		if missing := missingGlyphs(face, text); len(missing) > 0 {
+			log.Printf("Watermark font %s cannot render %q, skipping text watermark", opts.Font, string(missing))
+			return dc.Image(), nil
+		}
+		dc.SetFontFace(face)
+
+		r, g, b := parseColor(opts.Color)
+		if opts.Position == PositionTile {
+			drawTiled(dc, text, r, g, b, opts.Opacity)
+			return dc.Image(), nil
+		}
+
+		x, y, ax, ay := anchor(opts.Position, w, h, margin)
+		// gg 的文字锚点以基线为准，ay = 1 表示 y 是文字顶部，与图片锚点相反
+		ay = 1 - ay
+		// 先画一层淡淡的阴影，浅色背景上也能看清
+		offset := math.Max(1, float64(face.Metrics().Height.Round())/16)
+		dc.SetRGBA(0, 0, 0, opts.Opacity*0.4)
+		dc.DrawStringAnchored(text, x+offset, y+offset, ax, ay)
+		dc.SetRGBA(r, g, b, opts.Opacity)
+		dc.DrawStringAnchored(text, x, y, ax, ay)
+		return dc.Image(), nil
+	}
+}
+
+// missingGlyphs 返回 face 中没有字形的字符，每个字符只出现一次
+func missingGlyphs(face font.Face, text string) []rune {
+	var missing []rune
+	seen := make(map[rune]bool)
+	for _, r := range text {
+		if unicode.IsSpace(r) || seen[r] {
+			continue
+		}
+		seen[r] = true
+		if _, ok := face.GlyphAdvance(r); !ok {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+// drawTiled 以 -30° 斜向重复绘制文字
+func drawTiled(dc *gg.Context, text string, r, g, b, opacity float64) {
+	w, h := float64(dc.Width()), float64(dc.Height())
+	tw, th := dc.MeasureString(text)
+	stepX, stepY := tw*1.8, th*5
+	if stepX <= 0 || stepY <= 0 {
+		return
+	}
+
+	dc.Push()
+	defer dc.Pop()
+	dc.RotateAbout(gg.Radians(-30), w/2, h/2)
+	dc.SetRGBA(r, g, b, opacity)
+	// 旋转后需要覆盖对角线长度的区域
+	diagonal := math.Hypot(w, h)
+	row := 0
+	for y := h/2 - diagonal/2; y < h/2+diagonal/2; y += stepY {
+		shift := 0.0
+		if row%2 == 1 {
+			shift = stepX / 2
+		}
+		for x := w/2 - diagonal/2 - shift; x < w/2+diagonal/2; x += stepX {
+			dc.DrawStringAnchored(text, x, y, 0, 0.5)
+		}
+		row++
+	}
+}
+
+// drawLogo 按比例缩放 logo 并以 LogoOpacity 叠加到指定位置
+func drawLogo(dc *gg.Context, opts WatermarkOptions, margin float64) error {
+	logo, err := loadLogo(opts.Logo)
+	if err != nil {
+		return err
+	}
+	lb := logo.Bounds()
+	if lb.Dx() == 0 || lb.Dy() == 0 {
+		return fmt.Errorf("logo %s is empty", opts.Logo)
+	}
+
+	width := max(1, int(float64(dc.Width())*opts.LogoScale))
+	height := max(1, lb.Dy()*width/lb.Dx())
+	scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), logo, lb, draw.Src, nil)
+
+	// 乘上透明度
+	faded := image.NewNRGBA(scaled.Bounds())
+	mask := image.NewUniform(color.Alpha{A: uint8(opts.LogoOpacity * 255)})
+	draw.DrawMask(faded, faded.Bounds(), scaled, image.Point{}, mask, image.Point{}, draw.Over)
+
+	x, y, ax, ay := anchor(opts.LogoPosition, float64(dc.Width()), float64(dc.Height()), margin)
+	dc.DrawImageAnchored(faded, int(x), int(y), ax, ay)
+	return nil
+}
@@ -3,7 +3,12 @@ package main
 import (
 	"fmt"
 	"os"
+	"owlllovo/ginessential/ai"
 	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/controller"
+	"owlllovo/ginessential/queue"
+	"owlllovo/ginessential/storage"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
@@ -11,6 +16,12 @@ import (
 
 func main() {
 	InitConfig()
+	if err := storage.Init(); err != nil {
+		panic("Failed to init storage, err: " + err.Error())
+	}
+	if err := common.InitJWTKeys(); err != nil {
+		panic("Failed to load JWT keys, err: " + err.Error())
+	}
 	db := common.InitDB()
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -19,7 +30,6 @@ func main() {
 	defer sqlDB.Close()
 
 	r := gin.Default()
-	r.Static("/images", "./assets/images")
 	r = CollectRoute(r)
 	port := viper.GetString("server.port")
 
@@ -30,6 +40,14 @@ func main() {
 	}
 	fmt.Println("MaxTokens from config:", common.AppConfig.MaxTokens)
 
+	if err := ai.InitCritics(); err != nil {
+		panic("Failed to init AI providers, err: " + err.Error())
+	}
+	jobQueue := queue.Start(db, queue.LoadOptions())
+	defer jobQueue.Stop()
+	go common.PurgeExpiredTokens(time.Hour)
+	go controller.SweepOrphanAssets(controller.LoadAssetSweepOptions())
+
 	if port != "" {
 		panic(r.Run(":" + port)) // listen and serve on specified port in yml
 	} else {
@@ -23,29 +23,102 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		tokenString = tokenString[7:]
 
-		token, claims, err := common.ParseToken((tokenString))
-		if err != nil || !token.Valid {
+		user, claims, ok := authenticate(tokenString)
+		if !ok {
 			ctx.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Insufficient permissions"})
 			ctx.Abort()
 			return
 		}
 
-		// token validated, get userId in claim
-		userId := claims.UserId
-		DB := common.GetDB()
-		var user model.User
-		DB.First(&user, userId)
+		// user exist, write user informations
+		ctx.Set("user", user)
+		ctx.Set("claims", claims)
+		ctx.Next()
+
+	}
+}
 
-		// user don't exist
-		if user.ID == 0 {
+// OptionalAuthMiddleware 用于公开接口：带有效 token 时写入用户信息，否则按匿名用户继续
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if header := ctx.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			if user, claims, ok := authenticate(header[7:]); ok {
+				ctx.Set("user", user)
+				ctx.Set("claims", claims)
+			}
+		}
+		ctx.Next()
+	}
+}
+
+// WebSocketAuthMiddleware 与 AuthMiddleware 相同，但浏览器无法为 WebSocket 设置 header，
+// 所以也接受 ?ticket= 参数。ticket 是 POST /ws/ticket 换取的一次性凭证，URL 中不能出现 access token，
+// 否则访问日志会记录下 token
+func WebSocketAuthMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var (
+			user   model.User
+			claims *common.Claims
+			ok     bool
+		)
+		if header := ctx.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			user, claims, ok = authenticate(header[7:])
+		} else if ticket := ctx.Query("ticket"); ticket != "" {
+			user, claims, ok = authenticateTicket(ticket)
+		}
+		if !ok {
 			ctx.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Insufficient permissions"})
 			ctx.Abort()
 			return
 		}
 
-		// user exist, write user informations
 		ctx.Set("user", user)
+		ctx.Set("claims", claims)
 		ctx.Next()
+	}
+}
+
+// authenticateTicket 使用一次性连接凭证，并检查换取凭证的 token 是否已被吊销
+func authenticateTicket(ticket string) (model.User, *common.Claims, bool) {
+	var user model.User
+	claims, err := common.ConsumeWebSocketTicket(ticket)
+	if err != nil {
+		return user, nil, false
+	}
+	if err := common.GetDB().First(&user, claims.UserId).Error; err != nil {
+		return user, nil, false
+	}
+	if common.IsTokenRevoked(claims, user) {
+		return user, nil, false
+	}
+	return user, claims, true
+}
 
+// authenticate 校验 token、吊销列表并加载对应的用户
+func authenticate(tokenString string) (model.User, *common.Claims, bool) {
+	var user model.User
+	if tokenString == "" {
+		return user, nil, false
 	}
+
+	token, claims, err := common.ParseToken((tokenString))
+	if err != nil || !token.Valid {
+		return user, nil, false
+	}
+
+	// token validated, get userId in claim
+	userId := claims.UserId
+	DB := common.GetDB()
+	DB.First(&user, userId)
+
+	// user don't exist
+	if user.ID == 0 {
+		return user, nil, false
+	}
+
+	if common.IsTokenRevoked(claims, user) {
+		return user, nil, false
+	}
+
+	return user, claims, true
 }
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission 要求当前用户的角色拥有全部给定权限，需要放在 AuthMiddleware 之后
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, exists := ctx.Get("user")
+		if !exists {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "Insufficient permissions"})
+			ctx.Abort()
+			return
+		}
+
+		role := user.(model.User).Role
+		for _, permission := range permissions {
+			if !common.HasPermission(role, permission) {
+				ctx.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "Insufficient permissions"})
+				ctx.Abort()
+				return
+			}
+		}
+
+		ctx.Next()
+	}
+}
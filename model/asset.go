@@ -0,0 +1,56 @@
+package model
+
+import (
+	"owlllovo/ginessential/imaging"
+
+	"gorm.io/gorm"
+)
+
+// Asset 是一次图片上传，StorageKey 是原图在存储后端中的 key，其它尺寸由 imaging.RenditionNames 推算。
+// 没有帖子引用的 Asset 超过保留期后由清理任务删除
+type Asset struct {
+	ID         uint   `json:"id" gorm:"primarykey"`
+	UserID     uint   `json:"user_id" gorm:"not null;index"`
+	StorageKey string `json:"storage_key" gorm:"type:varchar(128);not null;uniqueIndex"`
+	// Size 是所有尺寸文件的总字节数
+	Size   int64  `json:"size" gorm:"not null"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	MIME   string `json:"mime" gorm:"type:varchar(32)"`
+	Hash   string `json:"hash" gorm:"type:char(64);index"` // 上传文件的 sha256
+	// PHash 是感知哈希，用于查找重复或近似的画作；0 表示没有计算（旧数据或纯色图片）
+	PHash uint64 `json:"phash,string" gorm:"column:phash;not null;default:0"`
+	// PHash0 到 PHash3 是 PHash 的四段（imaging.Bands），各自建索引，用来筛选近似图片的候选
+	PHash0    uint16 `json:"-" gorm:"column:phash0;not null;default:0;index"`
+	PHash1    uint16 `json:"-" gorm:"column:phash1;not null;default:0;index"`
+	PHash2    uint16 `json:"-" gorm:"column:phash2;not null;default:0;index"`
+	PHash3    uint16 `json:"-" gorm:"column:phash3;not null;default:0;index"`
+	CreatedAt Time   `json:"created_at" gorm:"type:timestamp;index"`
+	// Images 是各个尺寸图片的地址，查询后根据 StorageKey 填充
+	Images map[string]string `json:"images" gorm:"-"`
+}
+
+// BeforeSave 根据 PHash 填充分段
+func (asset *Asset) BeforeSave(tx *gorm.DB) (err error) {
+	bands := imaging.Bands(asset.PHash)
+	asset.PHash0, asset.PHash1, asset.PHash2, asset.PHash3 = bands[0], bands[1], bands[2], bands[3]
+	return nil
+}
+
+func (asset *Asset) AfterFind(tx *gorm.DB) (err error) {
+	asset.Images = imaging.URLs(asset.StorageKey)
+	return nil
+}
+
+// Keys 返回该上传在存储后端中的所有 key
+func (asset Asset) Keys() []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, key := range imaging.RenditionNames(asset.StorageKey) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
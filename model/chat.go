@@ -1,20 +1,35 @@
 package model
 
 import (
+	"time"
+
 	uuid "github.com/satori/go.uuid"
 	"gorm.io/gorm"
 )
 
 type Chat struct {
 	gorm.Model
-	ID         uuid.UUID `gorm:"type:char(36);primary_key"`
-	SenderID   uint      `gorm:"not null"`
-	ReceiverID uint      `gorm:"not null"`
-	PostID     uuid.UUID `gorm:"type:char(36);not null"`
-	Messages   []Message `gorm:"foreignKey:ChatID"`
+	ID             uuid.UUID  `gorm:"type:char(36);primary_key"`
+	SenderID       uint       `gorm:"not null"`
+	ReceiverID     uint       `gorm:"not null"`
+	PostID         uuid.UUID  `gorm:"type:char(36);not null"`
+	Messages       []Message  `gorm:"foreignKey:ChatID"`
+	SenderReadAt   *time.Time // 发起方已读到的最后一条消息的时间
+	ReceiverReadAt *time.Time // 接收方已读到的最后一条消息的时间
+	// created_at 只精确到秒，同一秒内的消息再按 id 排序，所以已读游标是 (时间, 消息 id)
+	SenderReadMessageID   string `gorm:"type:char(36);not null;default:''"`
+	ReceiverReadMessageID string `gorm:"type:char(36);not null;default:''"`
 }
 
 func (chat *Chat) BeforeCreate(tx *gorm.DB) (err error) {
 	chat.ID = uuid.NewV4()
 	return
 }
+
+// ReadCursorColumns 返回 userID 在这个 Chat 中的已读游标列名：时间列和消息 id 列
+func (chat *Chat) ReadCursorColumns(userID uint) (string, string) {
+	if chat.SenderID == userID {
+		return "sender_read_at", "sender_read_message_id"
+	}
+	return "receiver_read_at", "receiver_read_message_id"
+}
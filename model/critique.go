@@ -0,0 +1,45 @@
+package model
+
+import (
+	uuid "github.com/satori/go.uuid"
+)
+
+// PostCritique 是 AI 对帖子的一次点评，每个维度 1-10 分；重新生成时会新增一个版本，旧版本保留。
+// provider 没有按结构化格式输出时 Structured 为 false，只有 Summary 有内容
+type PostCritique struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	PostID      uuid.UUID `json:"post_id" gorm:"type:char(36);not null;uniqueIndex:idx_post_critique_version"`
+	CommentID   uuid.UUID `json:"comment_id" gorm:"type:char(36);index"`
+	Version     int       `json:"version" gorm:"not null;default:1;uniqueIndex:idx_post_critique_version"`
+	Structured  bool      `json:"structured"`
+	Content     int       `json:"content"`
+	Composition int       `json:"composition"`
+	Color       int       `json:"color"`
+	Technique   int       `json:"technique"`
+	Creativity  int       `json:"creativity"`
+	Summary     string    `json:"summary" gorm:"type:text"`
+	Strengths   []string  `json:"strengths" gorm:"type:text;serializer:json"`
+	Suggestions []string  `json:"suggestions" gorm:"type:text;serializer:json"`
+	// 生成点评时使用的提示词模板，0 表示默认模板
+	PromptTemplateID uint   `json:"prompt_template_id"`
+	PromptVersion    int    `json:"prompt_version"`
+	Provider         string `json:"provider" gorm:"type:varchar(50)"`
+	Model            string `json:"model" gorm:"type:varchar(100)"`
+	CreatedAt        Time   `json:"created_at" gorm:"type:timestamp"`
+}
+
+const (
+	RatingUp   = 1
+	RatingDown = -1
+)
+
+// CritiqueRating 是用户对一次 AI 点评的评价，每人每条点评一个评价
+type CritiqueRating struct {
+	ID         uint   `json:"id" gorm:"primarykey"`
+	CritiqueID uint   `json:"critique_id" gorm:"not null;uniqueIndex:idx_critique_rating_user"`
+	UserID     uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_critique_rating_user"`
+	Rating     int    `json:"rating" gorm:"not null"`
+	Reason     string `json:"reason" gorm:"type:varchar(255)"`
+	CreatedAt  Time   `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt  Time   `json:"updated_at" gorm:"type:timestamp"`
+}
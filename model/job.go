@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job 是持久化的后台任务，由 queue 包的 worker 执行
+type Job struct {
+	ID        uint       `json:"id" gorm:"primarykey"`
+	Type      string     `json:"type" gorm:"type:varchar(50);not null"`
+	Payload   string     `json:"payload" gorm:"type:text"`
+	Status    string     `json:"status" gorm:"type:varchar(20);not null;default:'pending';index:idx_jobs_status_next_run,priority:1"`
+	Attempts  int        `json:"attempts" gorm:"not null;default:0"`
+	NextRunAt time.Time  `json:"next_run_at" gorm:"not null;index:idx_jobs_status_next_run,priority:2"`
+	LockedAt  *time.Time `json:"locked_at"`
+	LockedBy  string     `json:"locked_by" gorm:"type:varchar(100)"`
+	LastError string     `json:"last_error" gorm:"type:text"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	PostDraft    = "Draft"
+	PostPending  = "Pending"
+	PostApproved = "Approved"
+	PostRejected = "Rejected"
+	PostArchived = "Archived"
+)
+
+const (
+	ActorAuthor    = "author"
+	ActorModerator = "moderator"
+	// ActorSystem 是自动化流程，例如 AI 预审自动通过
+	ActorSystem = "system"
+)
+
+// postTransitions 列出允许的状态转换以及谁可以执行
+var postTransitions = map[string]map[string][]string{
+	PostDraft: {
+		PostPending: {ActorAuthor},
+	},
+	PostPending: {
+		PostApproved: {ActorModerator, ActorSystem},
+		PostRejected: {ActorModerator},
+		PostDraft:    {ActorAuthor},
+	},
+	PostApproved: {
+		PostPending:  {ActorAuthor},
+		PostArchived: {ActorAuthor, ActorModerator},
+	},
+	PostRejected: {
+		PostPending:  {ActorAuthor},
+		PostDraft:    {ActorAuthor},
+		PostArchived: {ActorAuthor, ActorModerator},
+	},
+	PostArchived: {
+		PostPending: {ActorAuthor},
+	},
+}
+
+// CanTransitionPost 判断 actor 能否把帖子从 from 状态改为 to 状态
+func CanTransitionPost(from, to, actor string) bool {
+	for _, allowed := range postTransitions[from][to] {
+		if allowed == actor {
+			return true
+		}
+	}
+	return false
+}
+
+// PostModeration 记录帖子每一次状态变化
+type PostModeration struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	PostID     uuid.UUID `json:"post_id" gorm:"type:char(36);not null;index"`
+	FromStatus string    `json:"from_status" gorm:"type:varchar(20)"`
+	ToStatus   string    `json:"to_status" gorm:"type:varchar(20);not null"`
+	ActorID    uint      `json:"actor_id" gorm:"not null"`
+	Actor      *User     `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+	Reason     string    `json:"reason" gorm:"type:varchar(255)"`
+	Note       string    `json:"note" gorm:"type:text"`
+	CreatedAt  Time      `json:"created_at" gorm:"type:timestamp"`
+}
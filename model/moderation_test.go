@@ -0,0 +1,41 @@
+package model
+
+import "testing"
+
+func TestCanTransitionPost(t *testing.T) {
+	tests := []struct {
+		name  string
+		from  string
+		to    string
+		actor string
+		want  bool
+	}{
+		{"author submits draft", PostDraft, PostPending, ActorAuthor, true},
+		{"moderator cannot submit draft", PostDraft, PostPending, ActorModerator, false},
+		{"draft cannot be approved directly", PostDraft, PostApproved, ActorModerator, false},
+		{"moderator approves", PostPending, PostApproved, ActorModerator, true},
+		{"system auto-approves", PostPending, PostApproved, ActorSystem, true},
+		{"author cannot approve own post", PostPending, PostApproved, ActorAuthor, false},
+		{"moderator rejects", PostPending, PostRejected, ActorModerator, true},
+		{"system cannot reject", PostPending, PostRejected, ActorSystem, false},
+		{"author withdraws to draft", PostPending, PostDraft, ActorAuthor, true},
+		{"author edits approved post", PostApproved, PostPending, ActorAuthor, true},
+		{"moderator archives approved post", PostApproved, PostArchived, ActorModerator, true},
+		{"approved cannot be rejected", PostApproved, PostRejected, ActorModerator, false},
+		{"author resubmits rejected post", PostRejected, PostPending, ActorAuthor, true},
+		{"moderator cannot approve rejected post", PostRejected, PostApproved, ActorModerator, false},
+		{"author restores archived post", PostArchived, PostPending, ActorAuthor, true},
+		{"moderator cannot restore archived post", PostArchived, PostApproved, ActorModerator, false},
+		{"same status is not a transition", PostPending, PostPending, ActorModerator, false},
+		{"unknown status", "Deleted", PostPending, ActorAuthor, false},
+		{"unknown actor", PostPending, PostApproved, "guest", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanTransitionPost(tt.from, tt.to, tt.actor); got != tt.want {
+				t.Errorf("CanTransitionPost(%q, %q, %q) = %v, want %v", tt.from, tt.to, tt.actor, got, tt.want)
+			}
+		})
+	}
+}
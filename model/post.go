@@ -1,6 +1,10 @@
 package model
 
 import (
+	"time"
+
+	"owlllovo/ginessential/imaging"
+
 	uuid "github.com/satori/go.uuid"
 	"gorm.io/gorm"
 )
@@ -12,15 +16,34 @@ type Post struct {
 	CategoryId uint `json:"category_id" gorm:"not null"`
 	Category   *Category
 	Title      string    `json:"title" gorm:"type:varchar(50); not null"`
-	HeadImg    string    `json:"head_img"`
+	HeadImg    string    `json:"head_img"` // 封面原图的 key，与 Asset.StorageKey 一致
+	AssetID    *uint     `json:"asset_id" gorm:"index"`
+	Asset      *Asset    `json:"asset,omitempty"`
 	Content    string    `json:"content" gorm:"type:text;not null"`
+	ChildAge   int       `json:"child_age" gorm:"not null;default:0"` // 作者孩子的年龄，0 表示未填写
 	CreatedAt  Time      `json:"created_at" gorm:"type:timestamp"`
 	UpdatedAt  Time      `json:"updated_at" gorm:"type:timestamp"`
 	Comments   []Comment `json:"comments"`                                          // 关联评论
 	Status     string    `json:"status" gorm:"type:varchar(20); default:'Pending'"` // Added status field with a default value of 'Pending'
+	// 最近一次被驳回的原因和审核备注；备注只给审核人员看，不随帖子序列化
+	RejectionReason string `json:"rejection_reason" gorm:"type:varchar(255)"`
+	ModeratorNote   string `json:"-" gorm:"type:text"`
+	// 最近一次进入待审核的时间，以及审核队列中领取该帖子的审核人员
+	SubmittedAt *time.Time `json:"submitted_at" gorm:"index"`
+	ClaimedBy   uint       `json:"claimed_by" gorm:"not null;default:0"`
+	ClaimedAt   *time.Time `json:"claimed_at"`
+	// Images 是各个尺寸图片的地址，查询后根据 HeadImg 填充
+	Images map[string]string `json:"images" gorm:"-"`
+	// 最近一次 AI 预审的结论，空表示还没有预审
+	ScreeningVerdict string `json:"screening_verdict" gorm:"type:varchar(16);index"`
 }
 
 func (post *Post) BeforeCreate(tx *gorm.DB) (err error) {
 	post.ID = uuid.NewV4() // 直接赋值，不检查错误
 	return nil
 }
+
+func (post *Post) AfterFind(tx *gorm.DB) (err error) {
+	post.Images = imaging.URLs(post.HeadImg)
+	return nil
+}
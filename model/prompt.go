@@ -0,0 +1,44 @@
+package model
+
+// PromptTemplate 是 AI 点评使用的提示词模板，可以限定分类和儿童年龄段，
+// CategoryID 为 0 表示所有分类，MinAge / MaxAge 为 0 表示不限
+type PromptTemplate struct {
+	ID         uint   `json:"id" gorm:"primarykey"`
+	Name       string `json:"name" gorm:"type:varchar(50);not null"`
+	CategoryID uint   `json:"category_id" gorm:"not null;default:0;index"`
+	MinAge     int    `json:"min_age" gorm:"not null;default:0"`
+	MaxAge     int    `json:"max_age" gorm:"not null;default:0"`
+	Body       string `json:"body" gorm:"type:text;not null"`
+	Version    int    `json:"version" gorm:"not null;default:1"`
+	Active     bool   `json:"active" gorm:"not null;default:true"`
+	UpdatedBy  uint   `json:"updated_by"`
+	CreatedAt  Time   `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt  Time   `json:"updated_at" gorm:"type:timestamp"`
+}
+
+// PromptTemplateVersion 保存模板每个版本的内容，用于查看历史和回滚
+type PromptTemplateVersion struct {
+	ID         uint   `json:"id" gorm:"primarykey"`
+	TemplateID uint   `json:"template_id" gorm:"not null;uniqueIndex:idx_template_version"`
+	Version    int    `json:"version" gorm:"not null;uniqueIndex:idx_template_version"`
+	Body       string `json:"body" gorm:"type:text;not null"`
+	CreatedBy  uint   `json:"created_by"`
+	CreatedAt  Time   `json:"created_at" gorm:"type:timestamp"`
+}
+
+// Matches 判断模板是否适用于该分类和年龄，age 为 0 表示年龄未知
+func (t PromptTemplate) Matches(categoryID uint, age int) bool {
+	if !t.Active {
+		return false
+	}
+	if t.CategoryID != 0 && t.CategoryID != categoryID {
+		return false
+	}
+	if t.MinAge == 0 && t.MaxAge == 0 {
+		return true
+	}
+	if age == 0 {
+		return false
+	}
+	return (t.MinAge == 0 || age >= t.MinAge) && (t.MaxAge == 0 || age <= t.MaxAge)
+}
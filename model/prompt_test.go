@@ -0,0 +1,35 @@
+package model
+
+import "testing"
+
+func TestPromptTemplateMatches(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   PromptTemplate
+		categoryID uint
+		age        int
+		want       bool
+	}{
+		{"generic template", PromptTemplate{Active: true}, 3, 7, true},
+		{"generic template, unknown age", PromptTemplate{Active: true}, 3, 0, true},
+		{"inactive", PromptTemplate{}, 3, 7, false},
+		{"same category", PromptTemplate{Active: true, CategoryID: 3}, 3, 7, true},
+		{"other category", PromptTemplate{Active: true, CategoryID: 4}, 3, 7, false},
+		{"inside age range", PromptTemplate{Active: true, MinAge: 6, MaxAge: 8}, 3, 7, true},
+		{"lower bound inclusive", PromptTemplate{Active: true, MinAge: 6, MaxAge: 8}, 3, 6, true},
+		{"upper bound inclusive", PromptTemplate{Active: true, MinAge: 6, MaxAge: 8}, 3, 8, true},
+		{"below age range", PromptTemplate{Active: true, MinAge: 6, MaxAge: 8}, 3, 5, false},
+		{"above age range", PromptTemplate{Active: true, MinAge: 6, MaxAge: 8}, 3, 9, false},
+		{"open-ended minimum", PromptTemplate{Active: true, MinAge: 10}, 3, 15, true},
+		{"open-ended maximum", PromptTemplate{Active: true, MaxAge: 5}, 3, 3, true},
+		{"age range needs a known age", PromptTemplate{Active: true, MinAge: 6, MaxAge: 8}, 3, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.template.Matches(tt.categoryID, tt.age); got != tt.want {
+				t.Errorf("Matches(%d, %d) = %v, want %v", tt.categoryID, tt.age, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package model
+
+const (
+	RoleAdmin     = "Admin"
+	RoleModerator = "Moderator"
+	RoleUser      = "User"
+	RoleAI        = "AI"
+)
+
+const (
+	PermPostApprove   = "post:approve"
+	PermPostEditAny   = "post:edit_any"
+	PermPostDeleteAny = "post:delete_any"
+	PermUserManage    = "user:manage"
+	PermRoleManage    = "role:manage"
+	PermCategoryWrite = "category:write"
+	PermPromptManage  = "prompt:manage"
+	PermAIUsage       = "ai:usage"
+)
+
+// Permission 是一个可以授予角色的操作权限，如 post:approve
+type Permission struct {
+	ID          uint   `json:"id" gorm:"primarykey"`
+	Name        string `json:"name" gorm:"type:varchar(50);not null;unique"`
+	Description string `json:"description" gorm:"type:varchar(255)"`
+}
+
+// Role 是权限的集合，User.Role 保存角色名称
+type Role struct {
+	ID          uint         `json:"id" gorm:"primarykey"`
+	Name        string       `json:"name" gorm:"type:varchar(20);not null;unique"`
+	Description string       `json:"description" gorm:"type:varchar(255)"`
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions"`
+	CreatedAt   Time         `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt   Time         `json:"updated_at" gorm:"type:timestamp"`
+}
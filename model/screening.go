@@ -0,0 +1,27 @@
+package model
+
+import (
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	ScreeningSafe   = "safe"
+	ScreeningReview = "review"
+	ScreeningUnsafe = "unsafe"
+)
+
+// PostScreening 记录 AI 对帖子图片的一次预审结果，HeadImg 是预审时的图片，帖子换图后结论不再适用
+type PostScreening struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	PostID       uuid.UUID `json:"post_id" gorm:"type:char(36);not null;index"`
+	HeadImg      string    `json:"head_img" gorm:"type:varchar(128)"`
+	AssetID      *uint     `json:"asset_id"`
+	Verdict      string    `json:"verdict" gorm:"type:varchar(16);not null"`
+	Confidence   float64   `json:"confidence"`
+	Reasons      []string  `json:"reasons" gorm:"type:text;serializer:json"`
+	AutoApproved bool      `json:"auto_approved"`
+	Provider     string    `json:"provider" gorm:"type:varchar(50)"`
+	Model        string    `json:"model" gorm:"type:varchar(100)"`
+	Raw          string    `json:"-" gorm:"type:text"`
+	CreatedAt    Time      `json:"created_at" gorm:"type:timestamp"`
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// RefreshToken 是服务端保存的刷新令牌，只保存哈希；同一次登录轮换出的令牌共享 SessionID
+type RefreshToken struct {
+	ID         uint      `gorm:"primarykey"`
+	UserID     uint      `gorm:"not null;index"`
+	SessionID  string    `gorm:"type:char(36);not null;index"`
+	TokenHash  string    `gorm:"type:char(64);not null;uniqueIndex"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	RevokedAt  *time.Time
+	ReplacedBy uint
+	CreatedAt  time.Time
+}
+
+// RevokedToken 是吊销列表，ID 为 access token 的 jti 或整个会话的 SessionID
+type RevokedToken struct {
+	ID        string    `gorm:"type:varchar(64);primarykey"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+// WebSocketTicket 是一次性的 WebSocket 连接凭证，浏览器无法为 WebSocket 设置 header，
+// 用它代替 access token 放在 URL 中，避免 token 出现在访问日志里。ID 为凭证的 sha256
+type WebSocketTicket struct {
+	ID        string    `gorm:"type:char(64);primarykey"`
+	UserID    uint      `gorm:"not null"`
+	SessionID string    `gorm:"type:char(36)"`
+	TokenID   string    `gorm:"type:varchar(64)"` // 换取凭证的 access token 的 jti
+	IssuedAt  int64     // 换取凭证的 access token 的签发时间
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	UsageChatReply     = "chat_reply"
+	UsagePostCritique  = "post_critique"
+	UsagePostScreening = "post_screening"
+)
+
+// AIUsage 记录每一次 AI 调用的用量和估算费用，失败的调用 Error 不为空
+type AIUsage struct {
+	ID               uint      `json:"id" gorm:"primarykey"`
+	UserID           uint      `json:"user_id" gorm:"not null;index:idx_ai_usage_user_time"`
+	PostID           uuid.UUID `json:"post_id" gorm:"type:char(36);index"`
+	Purpose          string    `json:"purpose" gorm:"type:varchar(20);not null"`
+	Provider         string    `json:"provider" gorm:"type:varchar(50)"`
+	Model            string    `json:"model" gorm:"type:varchar(100)"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Estimated        bool      `json:"estimated"`
+	LatencyMs        int64     `json:"latency_ms"`
+	Cost             float64   `json:"cost"`
+	Error            string    `json:"error" gorm:"type:varchar(255)"`
+	CreatedAt        Time      `json:"created_at" gorm:"type:timestamp;index:idx_ai_usage_user_time"`
+}
+
+// AIQuota 是单个用户的 AI 调用次数限额，覆盖角色的默认限额；0 表示不限
+type AIQuota struct {
+	UserID  uint `json:"user_id" gorm:"primarykey;autoIncrement:false"`
+	Daily   int  `json:"daily"`
+	Monthly int  `json:"monthly"`
+}
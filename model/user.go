@@ -1,11 +1,16 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
 	gorm.Model
-	Name      string `gorm:"type:varchar(20);not null"`
-	Telephone string `gorm:"varchar(11);not null;"`
-	Password  string `gorm:"size:255;not null"`
-	Role      string `gorm:"type:varchar(20);not null"`
+	Name             string     `gorm:"type:varchar(20);not null"`
+	Telephone        string     `gorm:"varchar(11);not null;"`
+	Password         string     `json:"-" gorm:"size:255;not null"`
+	Role             string     `gorm:"type:varchar(20);not null"`
+	TokensValidAfter *time.Time `json:"-"` // 早于该时间签发的 token 全部失效，用于"退出所有设备"
 }
@@ -0,0 +1,313 @@
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"owlllovo/ginessential/model"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// Handler 执行一个任务，返回错误时任务会按退避策略重试
+type Handler func(job *model.Job) error
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]Handler{}
+
+	// wake 用于在入队后立即唤醒空闲的 worker
+	wake = make(chan struct{}, 1)
+)
+
+// Register 注册某种任务类型的处理函数
+func Register(jobType string, handler Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[jobType] = handler
+}
+
+func handlerFor(jobType string) (Handler, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	h, ok := handlers[jobType]
+	return h, ok
+}
+
+// Enqueue 插入一个待执行的任务，db 可以是事务，这样任务与业务数据一起提交
+func Enqueue(db *gorm.DB, jobType string, payload interface{}) (*model.Job, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := model.Job{
+		Type:      jobType,
+		Payload:   string(payloadBytes),
+		Status:    model.JobPending,
+		NextRunAt: time.Now(),
+	}
+	if err := db.Create(&job).Error; err != nil {
+		return nil, err
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+	return &job, nil
+}
+
+// Decode 把任务的 payload 解析到 v
+func Decode(job *model.Job, v interface{}) error {
+	return json.Unmarshal([]byte(job.Payload), v)
+}
+
+// Options 控制 worker 数量、轮询间隔和重试策略
+type Options struct {
+	Workers      int
+	MaxAttempts  int
+	PollInterval time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	LockTimeout  time.Duration
+}
+
+// LoadOptions 从 application.yml 的 queue 节点读取配置，缺省项使用默认值
+func LoadOptions() Options {
+	opts := Options{
+		Workers:      viper.GetInt("queue.workers"),
+		MaxAttempts:  viper.GetInt("queue.maxAttempts"),
+		PollInterval: viper.GetDuration("queue.pollInterval"),
+		BaseBackoff:  viper.GetDuration("queue.baseBackoff"),
+		MaxBackoff:   viper.GetDuration("queue.maxBackoff"),
+		LockTimeout:  viper.GetDuration("queue.lockTimeout"),
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = 30 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Hour
+	}
+	if opts.LockTimeout <= 0 {
+		opts.LockTimeout = 10 * time.Minute
+	}
+	return opts
+}
+
+// Queue 是基于 jobs 表的 worker pool
+type Queue struct {
+	db       *gorm.DB
+	opts     Options
+	workerID string
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newWorkerID 返回本进程的标识：主机名 + pid + 随机数，容器之间主机名相同也不会冲突
+func newWorkerID() string {
+	hostname, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// Start 迁移 jobs 表，恢复锁已超时的任务，并启动 worker
+func Start(db *gorm.DB, opts Options) *Queue {
+	db.AutoMigrate(&model.Job{})
+
+	q := &Queue{db: db, opts: opts, workerID: newWorkerID(), stop: make(chan struct{})}
+	// 无法区分崩溃的进程和仍在运行的其他实例，只恢复锁超过 LockTimeout 的任务
+	q.recover()
+
+	for i := 0; i < opts.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	q.wg.Add(1)
+	go q.watchStale()
+
+	log.Printf("Job queue started with %d workers", opts.Workers)
+	return q
+}
+
+// Stop 通知所有 worker 退出并等待正在执行的任务完成
+func (q *Queue) Stop() {
+	close(q.stop)
+	q.wg.Wait()
+}
+
+// recover 把锁已超时的 running 任务重新放回 pending；已经用完重试次数的任务（比如每次都让 worker 崩溃的任务）直接标记为失败
+func (q *Queue) recover() {
+	stale := q.db.Where("locked_at IS NULL OR locked_at <= ?", time.Now().Add(-q.opts.LockTimeout))
+
+	result := q.db.Model(&model.Job{}).
+		Where("status = ? AND attempts >= ?", model.JobRunning, q.opts.MaxAttempts).Where(stale).
+		Updates(map[string]interface{}{"status": model.JobFailed, "last_error": "lock expired on the last attempt", "locked_at": nil, "locked_by": ""})
+	if result.Error != nil {
+		log.Printf("Failed to fail exhausted stale jobs: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Marked %d stale jobs as failed after %d attempts", result.RowsAffected, q.opts.MaxAttempts)
+	}
+
+	result = q.db.Model(&model.Job{}).
+		Where("status = ? AND attempts < ?", model.JobRunning, q.opts.MaxAttempts).Where(stale).
+		Updates(map[string]interface{}{"status": model.JobPending, "next_run_at": time.Now(), "locked_at": nil, "locked_by": ""})
+	if result.Error != nil {
+		log.Printf("Failed to recover stale jobs: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Recovered %d stale jobs", result.RowsAffected)
+	}
+}
+
+func (q *Queue) watchStale() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(q.opts.LockTimeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+			q.recover()
+		}
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		// 有任务就连续执行，队列空了再等待
+		for {
+			select {
+			case <-q.stop:
+				return
+			default:
+			}
+			job, err := q.claim()
+			if err != nil {
+				log.Printf("Failed to claim job: %v", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			q.run(job)
+		}
+
+		select {
+		case <-q.stop:
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim 取出一个到期的 pending 任务并标记为 running，多个实例并发时只有一个能抢到
+func (q *Queue) claim() (*model.Job, error) {
+	var job model.Job
+	err := q.db.Where("status = ? AND next_run_at <= ? AND attempts < ?", model.JobPending, time.Now(), q.opts.MaxAttempts).
+		Order("next_run_at").First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := q.db.Model(&model.Job{}).
+		Where("id = ? AND status = ? AND attempts < ?", job.ID, model.JobPending, q.opts.MaxAttempts).
+		Updates(map[string]interface{}{
+			"status":    model.JobRunning,
+			"locked_at": now,
+			"locked_by": q.workerID,
+			"attempts":  gorm.Expr("attempts + 1"),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	job.Status = model.JobRunning
+	job.LockedAt = &now
+	job.Attempts++
+	return &job, nil
+}
+
+func (q *Queue) run(job *model.Job) {
+	err := q.execute(job)
+
+	updates := map[string]interface{}{"locked_at": nil, "locked_by": ""}
+	switch {
+	case err == nil:
+		updates["status"] = model.JobSucceeded
+		updates["last_error"] = ""
+	case job.Attempts >= q.opts.MaxAttempts:
+		log.Printf("Job %d (%s) failed permanently after %d attempts: %v", job.ID, job.Type, job.Attempts, err)
+		updates["status"] = model.JobFailed
+		updates["last_error"] = err.Error()
+	default:
+		delay := q.backoff(job.Attempts)
+		log.Printf("Job %d (%s) attempt %d failed, retrying in %s: %v", job.ID, job.Type, job.Attempts, delay, err)
+		updates["status"] = model.JobPending
+		updates["last_error"] = err.Error()
+		updates["next_run_at"] = time.Now().Add(delay)
+	}
+
+	// 锁超时后任务可能已被其他 worker 接手，这时不再覆盖它的状态
+	result := q.db.Model(&model.Job{}).Where("id = ? AND locked_by = ?", job.ID, q.workerID).Updates(updates)
+	if result.Error != nil {
+		log.Printf("Failed to update job %d: %v", job.ID, result.Error)
+	} else if result.RowsAffected == 0 {
+		log.Printf("Job %d (%s) was reclaimed by another worker after its lock expired", job.ID, job.Type)
+	}
+}
+
+func (q *Queue) execute(job *model.Job) (err error) {
+	handler, ok := handlerFor(job.Type)
+	if !ok {
+		// 没有处理函数的任务重试也不会成功
+		job.Attempts = q.opts.MaxAttempts
+		return fmt.Errorf("no handler registered for job type %q", job.Type)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(job)
+}
+
+// backoff 返回第 attempt 次失败后的等待时间：BaseBackoff * 2^(attempt-1)，不超过 MaxBackoff
+func (q *Queue) backoff(attempt int) time.Duration {
+	delay := q.opts.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= q.opts.MaxBackoff {
+			return q.opts.MaxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,145 @@
+package realtime
+
+import (
+	"log"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	EventMessage   = "message"
+	EventTyping    = "typing"
+	EventAck       = "ack"
+	EventDelivered = "delivered"
+	EventPing      = "ping"
+	EventPong      = "pong"
+	EventRead      = "read"
+	EventError     = "error"
+)
+
+// Event 是 WebSocket 上收发的 JSON 帧
+type Event struct {
+	Type      string      `json:"type"`
+	ChatID    string      `json:"chat_id,omitempty"`
+	MessageID string      `json:"message_id,omitempty"`
+	UserID    uint        `json:"user_id,omitempty"`
+	Message   interface{} `json:"message,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// Client 是一个已认证用户的 WebSocket 连接，同一用户可以有多个连接
+type Client struct {
+	UserID uint
+	conn   *websocket.Conn
+	send   chan Event
+}
+
+// Hub 维护在线连接，并把事件推送给指定用户的所有连接
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[uint]map[*Client]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{clients: map[uint]map[*Client]struct{}{}}
+}
+
+// DefaultHub 是进程内共享的 Hub
+var DefaultHub = NewHub()
+
+func (h *Hub) register(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[c.UserID] == nil {
+		h.clients[c.UserID] = map[*Client]struct{}{}
+	}
+	h.clients[c.UserID][c] = struct{}{}
+}
+
+func (h *Hub) unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[c.UserID][c]; !ok {
+		return
+	}
+	delete(h.clients[c.UserID], c)
+	if len(h.clients[c.UserID]) == 0 {
+		delete(h.clients, c.UserID)
+	}
+	close(c.send)
+}
+
+// Online 判断用户当前是否有连接
+func (h *Hub) Online(userID uint) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients[userID]) > 0
+}
+
+// SendToUsers 把事件推送给这些用户的所有连接；发送缓冲已满的慢连接会被断开
+func (h *Hub) SendToUsers(event Event, userIDs ...uint) {
+	var slow []*Client
+	h.mu.RLock()
+	seen := map[uint]bool{}
+	for _, userID := range userIDs {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		for c := range h.clients[userID] {
+			select {
+			case c.send <- event:
+			default:
+				slow = append(slow, c)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, c := range slow {
+		log.Printf("Dropping slow websocket client of user %d", c.UserID)
+		c.conn.Close()
+	}
+}
+
+// Serve 在当前 goroutine 中处理一个连接直到断开，收到的每个事件都交给 onEvent
+func (h *Hub) Serve(conn *websocket.Conn, userID uint, onEvent func(c *Client, event Event)) {
+	c := &Client{UserID: userID, conn: conn, send: make(chan Event, 32)}
+	h.register(c)
+	defer h.unregister(c)
+
+	go c.writeLoop()
+
+	for {
+		var event Event
+		if err := websocket.JSON.Receive(conn, &event); err != nil {
+			conn.Close()
+			return
+		}
+		if event.Type == EventPing {
+			c.Send(Event{Type: EventPong})
+			continue
+		}
+		onEvent(c, event)
+	}
+}
+
+// Send 向这个连接发送事件，缓冲已满时丢弃；只能在 Serve 的 onEvent 回调中使用
+func (c *Client) Send(event Event) {
+	select {
+	case c.send <- event:
+	default:
+	}
+}
+
+func (c *Client) writeLoop() {
+	for event := range c.send {
+		if err := websocket.JSON.Send(c.conn, event); err != nil {
+			c.conn.Close()
+			for range c.send {
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PromptRepository struct {
+	DB *gorm.DB
+}
+
+func NewPromptRepository() PromptRepository {
+	return PromptRepository{DB: common.GetDB()}
+}
+
+func (r PromptRepository) Create(template model.PromptTemplate) (*model.PromptTemplate, error) {
+	template.Version = 1
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&template).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.PromptTemplateVersion{
+			TemplateID: template.ID,
+			Version:    template.Version,
+			Body:       template.Body,
+			CreatedBy:  template.UpdatedBy,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// Update 修改模板；正文变化时生成新版本
+func (r PromptRepository) Update(id uint, changes model.PromptTemplate) (*model.PromptTemplate, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var current model.PromptTemplate
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, id).Error; err != nil {
+			return err
+		}
+
+		version := current.Version
+		if changes.Body != current.Body {
+			version++
+			if err := tx.Create(&model.PromptTemplateVersion{
+				TemplateID: id,
+				Version:    version,
+				Body:       changes.Body,
+				CreatedBy:  changes.UpdatedBy,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&current).Updates(map[string]interface{}{
+			"name":        changes.Name,
+			"category_id": changes.CategoryID,
+			"min_age":     changes.MinAge,
+			"max_age":     changes.MaxAge,
+			"body":        changes.Body,
+			"active":      changes.Active,
+			"version":     version,
+			"updated_by":  changes.UpdatedBy,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.SelectById(id)
+}
+
+// Rollback 把某个历史版本的正文作为新版本发布
+func (r PromptRepository) Rollback(id uint, version int, userId uint) (*model.PromptTemplate, error) {
+	current, err := r.SelectById(id)
+	if err != nil {
+		return nil, err
+	}
+	var old model.PromptTemplateVersion
+	if err := r.DB.Where("template_id = ? AND version = ?", id, version).First(&old).Error; err != nil {
+		return nil, err
+	}
+
+	changes := *current
+	changes.Body = old.Body
+	changes.UpdatedBy = userId
+	return r.Update(id, changes)
+}
+
+func (r PromptRepository) SelectById(id uint) (*model.PromptTemplate, error) {
+	var template model.PromptTemplate
+	if err := r.DB.First(&template, id).Error; err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (r PromptRepository) DeleteById(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", id).Delete(&model.PromptTemplateVersion{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.PromptTemplate{}, id).Error
+	})
+}
+
+func (r PromptRepository) ListAll() ([]model.PromptTemplate, error) {
+	var templates []model.PromptTemplate
+	if err := r.DB.Order("category_id, min_age, id").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (r PromptRepository) Versions(id uint) ([]model.PromptTemplateVersion, error) {
+	var versions []model.PromptTemplateVersion
+	if err := r.DB.Where("template_id = ?", id).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// Match 返回最适合该分类和年龄的模板：指定分类优先于通用模板，年龄段越窄越优先，
+// 同等条件下取最近修改的；没有匹配时返回 nil
+func (r PromptRepository) Match(categoryID uint, age int) (*model.PromptTemplate, error) {
+	var candidates []model.PromptTemplate
+	if err := r.DB.Where("active = ? AND category_id IN ?", true, []uint{0, categoryID}).
+		Order("updated_at DESC").Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	var best *model.PromptTemplate
+	for i := range candidates {
+		t := &candidates[i]
+		if !t.Matches(categoryID, age) {
+			continue
+		}
+		if best == nil || moreSpecific(*t, *best) {
+			best = t
+		}
+	}
+	return best, nil
+}
+
+// moreSpecific 判断模板 a 是否比 b 更具体
+func moreSpecific(a, b model.PromptTemplate) bool {
+	if (a.CategoryID != 0) != (b.CategoryID != 0) {
+		return a.CategoryID != 0
+	}
+	return ageSpan(a) < ageSpan(b)
+}
+
+// ageSpan 是模板覆盖的年龄范围宽度，不限的一端按 100 岁计算
+func ageSpan(t model.PromptTemplate) int {
+	max := t.MaxAge
+	if max == 0 {
+		max = 100
+	}
+	return max - t.MinAge
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"owlllovo/ginessential/model"
+	"testing"
+)
+
+func TestMoreSpecific(t *testing.T) {
+	generic := model.PromptTemplate{}
+	category := model.PromptTemplate{CategoryID: 3}
+	narrow := model.PromptTemplate{MinAge: 6, MaxAge: 8}
+	wide := model.PromptTemplate{MinAge: 4, MaxAge: 12}
+	openEnded := model.PromptTemplate{MinAge: 10}
+
+	tests := []struct {
+		name string
+		a, b model.PromptTemplate
+		want bool
+	}{
+		{"category beats generic", category, generic, true},
+		{"generic loses to category", generic, category, false},
+		{"category beats narrower generic age range", category, narrow, true},
+		{"narrow age range beats wide", narrow, wide, true},
+		{"wide age range loses to narrow", wide, narrow, false},
+		{"age range beats no age limit", wide, generic, true},
+		{"bounded range beats open-ended", wide, openEnded, true},
+		{"open-ended beats no age limit", openEnded, generic, true},
+		{"equal templates", narrow, narrow, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := moreSpecific(tt.a, tt.b); got != tt.want {
+				t.Errorf("moreSpecific(%+v, %+v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
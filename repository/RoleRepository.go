@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"fmt"
+	"owlllovo/ginessential/common"
+	"owlllovo/ginessential/model"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type RoleRepository struct {
+	DB *gorm.DB
+}
+
+func NewRoleRepository() RoleRepository {
+	return RoleRepository{DB: common.GetDB()}
+}
+
+// UnknownPermissionsError 表示请求中有不存在的权限名称
+type UnknownPermissionsError struct {
+	Names []string
+}
+
+func (e UnknownPermissionsError) Error() string {
+	return fmt.Sprintf("unknown permissions: %s", strings.Join(e.Names, ", "))
+}
+
+// findPermissions 按名称查询权限，有任何一个不存在时返回 UnknownPermissionsError
+func (r RoleRepository) findPermissions(names []string) ([]model.Permission, error) {
+	var permissions []model.Permission
+	if len(names) == 0 {
+		return permissions, nil
+	}
+	if err := r.DB.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		found[permission.Name] = true
+	}
+	var unknown []string
+	for _, name := range names {
+		if !found[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, UnknownPermissionsError{Names: unknown}
+	}
+	return permissions, nil
+}
+
+func (r RoleRepository) Create(name, description string, permissionNames []string) (*model.Role, error) {
+	permissions, err := r.findPermissions(permissionNames)
+	if err != nil {
+		return nil, err
+	}
+
+	role := model.Role{
+		Name:        name,
+		Description: description,
+		Permissions: permissions,
+	}
+	if err := r.DB.Create(&role).Error; err != nil {
+		return nil, err
+	}
+
+	common.InvalidatePermissions()
+	return &role, nil
+}
+
+func (r RoleRepository) Update(role model.Role, name, description string, permissionNames []string) (*model.Role, error) {
+	permissions, err := r.findPermissions(permissionNames)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.DB.Transaction(func(tx *gorm.DB) error {
+		if role.Name != name {
+			// User.Role 保存的是角色名称，改名时一并更新
+			if err := tx.Model(&model.User{}).Where("role = ?", role.Name).Update("role", name).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&role).Updates(map[string]interface{}{"name": name, "description": description}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&role).Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	common.InvalidatePermissions()
+	return r.SelectById(int(role.ID))
+}
+
+func (r RoleRepository) SelectById(id int) (*model.Role, error) {
+	var role model.Role
+	if err := r.DB.Preload("Permissions").First(&role, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+func (r RoleRepository) DeleteById(id int) error {
+	role, err := r.SelectById(id)
+	if err != nil {
+		return err
+	}
+
+	err = r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	common.InvalidatePermissions()
+	return nil
+}
+
+func (r RoleRepository) CountUsers(name string) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.User{}).Where("role = ?", name).Count(&count).Error
+	return count, err
+}
+
+func (r RoleRepository) ListAll() ([]model.Role, error) {
+	var roles []model.Role
+	if err := r.DB.Preload("Permissions").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r RoleRepository) ListPermissions() ([]model.Permission, error) {
+	var permissions []model.Permission
+	if err := r.DB.Order("name").Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"owlllovo/ginessential/controller"
 	"owlllovo/ginessential/middleware"
+	"owlllovo/ginessential/model"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,13 +13,21 @@ func CollectRoute(r *gin.Engine) *gin.Engine {
 	r.POST("/api/auth/register", controller.Register)
 	r.POST("/api/auth/login", controller.Login)
 	r.GET("/api/auth/info", middleware.AuthMiddleware(), controller.Info)
+	r.POST("/api/auth/refresh", controller.Refresh)
+	r.GET("/.well-known/jwks.json", controller.JWKS)
+	r.GET("/images/*key", controller.ServeImage)
+	r.HEAD("/images/*key", controller.ServeImage)
+	r.POST("/api/auth/logout", middleware.AuthMiddleware(), controller.Logout)
+	r.POST("/api/auth/logout/all", middleware.AuthMiddleware(), controller.LogoutAll)
 
 	categoryRoutes := r.Group("/categories")
 	categoryController := controller.NewCategoryController()
-	categoryRoutes.POST("", categoryController.Create)
-	categoryRoutes.PUT("/:id", categoryController.Update)
+	// 读取分类公开，修改分类需要 category:write 权限
+	categoryWrite := []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.RequirePermission(model.PermCategoryWrite)}
+	categoryRoutes.POST("", append(categoryWrite, categoryController.Create)...)
+	categoryRoutes.PUT("/:id", append(categoryWrite, categoryController.Update)...)
 	categoryRoutes.GET("/:id", categoryController.Show)
-	categoryRoutes.DELETE("/:id", categoryController.Delete)
+	categoryRoutes.DELETE("/:id", append(categoryWrite, categoryController.Delete)...)
 	categoryRoutes.GET("", categoryController.ListAll)
 
 	postRoutes := r.Group("/posts")
@@ -30,6 +39,10 @@ func CollectRoute(r *gin.Engine) *gin.Engine {
 	postRoutes.DELETE("/:id", postController.Delete)
 	postRoutes.POST("/page/list", postController.PageList)
 	postRoutes.POST("/upload", postController.UploadImage)
+	postRoutes.POST("/:id/submit", postController.SubmitPost)
+	postRoutes.POST("/:id/withdraw", postController.WithdrawPost)
+	postRoutes.POST("/:id/archive", postController.ArchivePost)
+	postRoutes.GET("/:id/moderation", postController.ModerationHistory)
 
 	// Like
 	LikeController := controller.NewLikeController()
@@ -38,26 +51,91 @@ func CollectRoute(r *gin.Engine) *gin.Engine {
 	postRoutes.GET("/:id/isliked", LikeController.IsLiked)
 	postRoutes.GET("/rank", LikeController.LikeRank)
 
-	r.GET("/user/:id", postController.GetUserPosts)
+	r.GET("/user/:id", middleware.OptionalAuthMiddleware(), postController.GetUserPosts)
 
 	// 添加评论相关的路由
 	CommentController := controller.NewCommentController()
 	postRoutes.POST("/:id/comments", CommentController.AddComment) // 添加评论
 	postRoutes.GET("/:id/comments", CommentController.GetComments) // 获取特定图书的所有评论
 
+	// AI 点评的历史版本、重新生成和评价
+	critiqueController := controller.NewCritiqueController()
+	postRoutes.GET("/:id/critiques", critiqueController.List)
+	postRoutes.POST("/:id/critiques/regenerate", critiqueController.Regenerate)
+	r.POST("/critiques/:id/rating", middleware.AuthMiddleware(), critiqueController.Rate)
+	r.DELETE("/critiques/:id/rating", middleware.AuthMiddleware(), critiqueController.Unrate)
+
 	adminRoutes := r.Group("/admin")
-	adminRoutes.Use(middleware.AdminAuthMiddleware())
-	adminRoutes.POST("/users", controller.Register)         // 创建用户
-	adminRoutes.PUT("/users/:id", controller.UpdateUser)    // 修改用户
-	adminRoutes.DELETE("/users/:id", controller.DeleteUser) // 删除用户
-	adminRoutes.GET("/users", controller.UserList)          // 用户列表
-	adminRoutes.GET("/users/:id", controller.GetUser)       // 单个用户
-	adminRoutes.POST("/posts/:id/approve", postController.ApprovePost)
+	adminRoutes.Use(middleware.AuthMiddleware())
+	userManage := middleware.RequirePermission(model.PermUserManage)
+	adminRoutes.POST("/users", userManage, controller.Register)              // 创建用户
+	adminRoutes.PUT("/users/:id", userManage, controller.UpdateUser)         // 修改用户
+	adminRoutes.DELETE("/users/:id", userManage, controller.DeleteUser)      // 删除用户
+	adminRoutes.GET("/users", userManage, controller.UserList)               // 用户列表
+	adminRoutes.GET("/users/:id", userManage, controller.GetUser)            // 单个用户
+	adminRoutes.POST("/users/:id/logout", userManage, controller.LogoutUser) // 强制用户退出所有设备
+	adminRoutes.POST("/posts/:id/approve", middleware.RequirePermission(model.PermPostApprove), postController.ApprovePost)
+	adminRoutes.POST("/posts/:id/reject", middleware.RequirePermission(model.PermPostApprove), postController.RejectPost)
+
+	// 待审核队列
+	queueController := controller.NewModerationQueueController()
+	queueRoutes := adminRoutes.Group("/queue")
+	queueRoutes.Use(middleware.RequirePermission(model.PermPostApprove))
+	queueRoutes.GET("", queueController.List)
+	queueRoutes.GET("/stats", queueController.Stats)
+	queueRoutes.POST("/approve", queueController.BulkApprove)
+	queueRoutes.POST("/reject", queueController.BulkReject)
+	queueRoutes.POST("/:id/claim", queueController.Claim)
+	queueRoutes.DELETE("/:id/claim", queueController.Release)
+
+	// 角色与权限管理
+	roleController := controller.NewRoleController()
+	roleRoutes := adminRoutes.Group("/roles")
+	roleRoutes.Use(middleware.RequirePermission(model.PermRoleManage))
+	roleRoutes.POST("", roleController.Create)
+	roleRoutes.PUT("/:id", roleController.Update)
+	roleRoutes.GET("/:id", roleController.Show)
+	roleRoutes.DELETE("/:id", roleController.Delete)
+	roleRoutes.GET("", roleController.ListAll)
+	adminRoutes.GET("/permissions", middleware.RequirePermission(model.PermRoleManage), roleController.ListPermissions)
+
+	// AI 用量报表与限额
+	usageController := controller.NewUsageController()
+	usageRoutes := adminRoutes.Group("/usage")
+	usageRoutes.Use(middleware.RequirePermission(model.PermAIUsage))
+	usageRoutes.GET("", usageController.Report)
+	usageRoutes.GET("/quotas/:id", usageController.ShowQuota)
+	usageRoutes.PUT("/quotas/:id", usageController.SetQuota)
+	usageRoutes.DELETE("/quotas/:id", usageController.DeleteQuota)
+	r.GET("/ai/usage", middleware.AuthMiddleware(), usageController.Mine)
+
+	// AI 点评提示词模板
+	promptController := controller.NewPromptController()
+	promptRoutes := adminRoutes.Group("/prompts")
+	promptRoutes.Use(middleware.RequirePermission(model.PermPromptManage))
+	promptRoutes.POST("", promptController.Create)
+	promptRoutes.POST("/preview", promptController.Preview)
+	promptRoutes.PUT("/:id", promptController.Update)
+	promptRoutes.GET("/:id", promptController.Show)
+	promptRoutes.DELETE("/:id", promptController.Delete)
+	promptRoutes.GET("", promptController.ListAll)
+	promptRoutes.GET("/:id/versions", promptController.Versions)
+	promptRoutes.POST("/:id/rollback", promptController.Rollback)
+	adminRoutes.GET("/critiques/ratings", middleware.RequirePermission(model.PermPromptManage), critiqueController.RatingReport)
+
+	// 疑似重复的画作，审核人员可以查看
+	duplicateController := controller.NewDuplicateController()
+	adminRoutes.GET("/duplicates", middleware.RequirePermission(model.PermPostApprove), duplicateController.Clusters)
 
 	chatController := controller.NewChatController()
 	r.POST("/message", middleware.AuthMiddleware(), chatController.SendMessage)
+	r.POST("/message/stream", middleware.AuthMiddleware(), chatController.StreamMessage)
 	r.GET("/messages", middleware.AuthMiddleware(), chatController.GetMessages)
 	r.GET("/chatlist", middleware.AuthMiddleware(), chatController.ChatList)
+	r.POST("/chats/:id/read", middleware.AuthMiddleware(), chatController.MarkRead)
+	r.GET("/chats/unread", middleware.AuthMiddleware(), chatController.UnreadCount)
+	r.POST("/ws/ticket", middleware.AuthMiddleware(), chatController.WebSocketTicket)
+	r.GET("/ws/chat", middleware.WebSocketAuthMiddleware(), chatController.Connect)
 
 	return r
 }
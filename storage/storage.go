@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	// ErrNotExist 表示对象不存在
+	ErrNotExist = errors.New("storage: object does not exist")
+	// ErrInvalidKey 表示 key 为空或试图跳出存储目录
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// Info 是对象的元数据
+type Info struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// Storage 是上传文件的存储后端，key 是不含目录前缀的文件名
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (*Info, error)
+	// URL 返回浏览器访问对象的地址
+	URL(key string) string
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultStorage Storage
+)
+
+// Init 按 storage.driver 创建默认的存储后端，支持 local 和 s3
+func Init() error {
+	driver := viper.GetString("storage.driver")
+	var (
+		s   Storage
+		err error
+	)
+	switch driver {
+	case "", "local":
+		s = NewLocal(viper.GetString("storage.local.root"), viper.GetString("storage.local.baseUrl"))
+	case "s3":
+		var opts S3Options
+		if err := viper.UnmarshalKey("storage.s3", &opts); err != nil {
+			return err
+		}
+		s, err = NewS3(opts)
+	default:
+		err = fmt.Errorf("storage: unknown driver %q", driver)
+	}
+	if err != nil {
+		return err
+	}
+	SetDefault(s)
+	return nil
+}
+
+// Default 返回默认的存储后端，未初始化时使用本地磁盘
+func Default() Storage {
+	defaultMu.RLock()
+	s := defaultStorage
+	defaultMu.RUnlock()
+	if s == nil {
+		return NewLocal("", "")
+	}
+	return s
+}
+
+// SetDefault 替换默认的存储后端
+func SetDefault(s Storage) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultStorage = s
+}
+
+// ReadAll 读取整个对象
+func ReadAll(ctx context.Context, s Storage, key string) ([]byte, error) {
+	body, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// validKey 拒绝空 key 和试图跳出存储目录的 key
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("%w %q", ErrInvalidKey, key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("%w %q", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
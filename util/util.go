@@ -1,6 +1,9 @@
 package util
 
-import "math/rand"
+import (
+	"math/rand"
+	"unicode/utf8"
+)
 
 func RandomString(n int) string {
 	var letters = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
@@ -12,3 +15,11 @@ func RandomString(n int) string {
 
 	return string(result)
 }
+
+// Truncate 截断到最多 n 个字符（rune），不会把多字节字符截成两半；MySQL 的 varchar(n) 按字符计长度
+func Truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
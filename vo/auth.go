@@ -0,0 +1,17 @@
+package vo
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// UserRequest 是注册、登录和修改用户的请求，字段名与原来直接绑定的 model.User 一致
+type UserRequest struct {
+	Name      string
+	Telephone string
+	Password  string
+	Role      string
+}
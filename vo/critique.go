@@ -0,0 +1,7 @@
+package vo
+
+// CritiqueRatingRequest 对 AI 点评点赞或点踩，Rating 为 "up" 或 "down"
+type CritiqueRatingRequest struct {
+	Rating string `json:"rating" binding:"required,oneof=up down"`
+	Reason string `json:"reason" binding:"max=255"`
+}
@@ -0,0 +1,11 @@
+package vo
+
+type SendMessageRequest struct {
+	ReceiverID uint   `json:"receiver_id"`
+	PostID     string `json:"post_id"`
+	Content    string `json:"content"`
+}
+
+type MarkReadRequest struct {
+	MessageID string `json:"message_id"`
+}
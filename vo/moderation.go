@@ -0,0 +1,13 @@
+package vo
+
+type ModerationRequest struct {
+	Reason string `json:"reason" binding:"max=255"`
+	Note   string `json:"note"`
+}
+
+// BulkModerationRequest 审核队列中批量通过或驳回帖子
+type BulkModerationRequest struct {
+	PostIDs []string `json:"post_ids" binding:"required,min=1,max=100"`
+	Reason  string   `json:"reason" binding:"max=255"`
+	Note    string   `json:"note"`
+}
@@ -3,7 +3,9 @@ package vo
 type CreatePostRequest struct {
 	CategoryName string `json:"category_name" binding:"required"`
 	Title        string `json:"title" binding:"required,max=10"`
-	HeadImg      string `json:"head_img"`
+	AssetID      uint   `json:"asset_id"` // 上传接口返回的 asset_id
+	HeadImg      string `json:"head_img"` // 旧客户端提交的文件名，必须是自己上传的图片
 	Content      string `json:"content" binding:"required"`
-	Status       string `json:"status"`
+	ChildAge     int    `json:"child_age" binding:"min=0,max=18"`
+	Status       string `json:"status"` // 创建时可传 "Draft" 保存为草稿，其它情况忽略
 }
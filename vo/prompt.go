@@ -0,0 +1,23 @@
+package vo
+
+type PromptTemplateRequest struct {
+	Name       string `json:"name" binding:"required,max=50"`
+	CategoryID uint   `json:"category_id"`
+	MinAge     int    `json:"min_age" binding:"min=0,max=18"`
+	MaxAge     int    `json:"max_age" binding:"min=0,max=18"`
+	Body       string `json:"body" binding:"required"`
+	Active     *bool  `json:"active"`
+}
+
+type PromptRollbackRequest struct {
+	Version int `json:"version" binding:"required,min=1"`
+}
+
+// PromptPreviewRequest 用示例数据渲染模板，字段为空时使用默认示例
+type PromptPreviewRequest struct {
+	Body     string `json:"body" binding:"required"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Category string `json:"category"`
+	Age      int    `json:"age"`
+}
@@ -0,0 +1,7 @@
+package vo
+
+type RoleRequest struct {
+	Name        string   `json:"name" binding:"required,max=20"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
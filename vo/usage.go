@@ -0,0 +1,7 @@
+package vo
+
+// AIQuotaRequest 设置单个用户的 AI 调用限额，0 表示不限
+type AIQuotaRequest struct {
+	Daily   int `json:"daily" binding:"min=0"`
+	Monthly int `json:"monthly" binding:"min=0"`
+}